@@ -2,6 +2,7 @@ package main
 
 import (
 	"crypto"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/mozey/httprouter-util/pkg/client"
 	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/share"
 	"github.com/mozey/logutil"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -42,6 +44,8 @@ func main() {
 		"token", "", "Auth token")
 	checksumFlag := flag.String(
 		"checksum", "", "Print checksum for specified path")
+	watchFlag := flag.Bool(
+		"watch", false, "Print a message when a new version is published")
 	flag.Parse()
 
 	c := client.NewHandler(conf)
@@ -67,6 +71,37 @@ func main() {
 			fmt.Println("already on the latest version")
 		}
 
+	} else if *watchFlag {
+		err := c.Watch(*tokenFlag, func(e client.Event) error {
+			var clientVersion share.ClientVersion
+			switch e.Name {
+			case share.EventClientVersion:
+				err := json.Unmarshal([]byte(e.Data), &clientVersion)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+			case share.EventReset:
+				// Events were missed, check the latest version
+				var err error
+				clientVersion, err = c.GetLatestVersion(*tokenFlag)
+				if err != nil {
+					return err
+				}
+			default:
+				return nil
+			}
+			if clientVersion.Version != conf.Version() {
+				fmt.Println(fmt.Sprintf(
+					"version %s published, run with -update",
+					clientVersion.Version))
+			}
+			return nil
+		})
+		if err != nil {
+			log.Error().Stack().Err(err).Msg("")
+			os.Exit(1)
+		}
+
 	} else if strings.TrimSpace(*checksumFlag) != "" {
 		payload, err := ioutil.ReadFile(*checksumFlag)
 		if err != nil {
@@ -1,20 +1,28 @@
 module github.com/mozey/httprouter-util
 
-go 1.14
+// Go 1.20 is required for http.ResponseController, ReverseProxy.Rewrite,
+// and by github.com/klauspost/compress
+go 1.20
 
 require (
-	github.com/NYTimes/gziphandler v1.1.1
 	github.com/alecthomas/units v0.0.0-20201120081800-1786d5ef83d4
-	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/andybalholm/brotli v1.1.1
+	github.com/gorilla/websocket v1.5.3
 	github.com/inconshreveable/go-update v0.0.0-20160112193335-8152e7eb6ccf
 	github.com/julienschmidt/httprouter v1.3.0
-	github.com/kr/pretty v0.1.0 // indirect
+	github.com/klauspost/compress v1.17.9
 	github.com/mozey/logutil v0.0.0-20200614125649-f2c2c4d6f582
 	github.com/pkg/errors v0.8.1
 	github.com/rs/cors v1.7.0
 	github.com/rs/zerolog v1.18.0
 	github.com/segmentio/ksuid v1.0.2
 	github.com/stretchr/testify v1.6.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/kr/pretty v0.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
 )
@@ -1,20 +1,31 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/NYTimes/gziphandler"
 	"github.com/alecthomas/units"
-	"github.com/julienschmidt/httprouter"
+	"github.com/gorilla/websocket"
 	"github.com/mozey/httprouter-util/pkg/config"
 	"github.com/mozey/httprouter-util/pkg/handler"
 	"github.com/mozey/httprouter-util/pkg/middleware"
+	"github.com/mozey/httprouter-util/pkg/openapi"
+	"github.com/mozey/httprouter-util/pkg/schema"
 	"github.com/mozey/httprouter-util/pkg/share"
+	schemadir "github.com/mozey/httprouter-util/schema"
+	"github.com/mozey/httprouter-util/www"
 	"github.com/pkg/errors"
 	"github.com/rs/cors"
 	"github.com/rs/zerolog/log"
@@ -23,12 +34,58 @@ import (
 // Handler for this service
 type Handler struct {
 	*handler.Handler
+	// ClientEvents are pushed to clients, e.g. when a new version is published
+	ClientEvents *handler.Broker
+	// WWW contains static assets and templates, see FS
+	WWW fs.FS
+	// Schemas used to validate requests and responses, see FS
+	Schemas fs.FS
+	// Assets serves static files from WWW
+	Assets *handler.Static
+	// Paginator for list routes
+	Paginator *handler.Paginator
+	// StreamPaginator allows larger pages for streaming routes
+	StreamPaginator *handler.Paginator
+
+	// Cached by LatestClientVersion
+	clientVersionMu      sync.Mutex
+	clientVersion        share.ClientVersion
+	clientVersionModTime time.Time
 }
 
 // NewHandler creates a new top level handler
 func NewHandler(conf *config.Config) (h *Handler) {
 	h = &Handler{}
 	h.Handler = handler.NewHandler(conf)
+	h.WWW = h.FS(www.FS, "www")
+	h.Schemas = h.FS(schemadir.FS, "schema")
+	h.Assets = handler.NewStatic("/www", &handler.StaticOptions{
+		FS: h.WWW,
+		CacheControl: []handler.CacheRule{
+			{Glob: "*.jpg", CacheControl: "public, max-age=86400"},
+			{Glob: "*.png", CacheControl: "public, max-age=86400"},
+		},
+		// Templates are not served
+		Exclude: []string{
+			handler.TemplateLayouts + "/*",
+			handler.TemplatePartials + "/*",
+			handler.TemplatePages + "/*",
+		},
+		Route: &handler.RouteOptions{
+			Name: "www", Description: "Static files",
+			Tags: []string{"www"}, Policy: share.PolicyPublic,
+		},
+	})
+	h.Renderer = h.LoadTemplates()
+	h.ClientEvents = handler.NewBroker(nil)
+	h.Paginator = handler.NewPaginator(&handler.PaginatorOptions{
+		Secret: h.PaginatorSecret(),
+	})
+	h.StreamPaginator = handler.NewPaginator(&handler.PaginatorOptions{
+		DefaultLimit: 100,
+		MaxLimit:     exampleItems,
+		Secret:       h.PaginatorSecret(),
+	})
 	return h
 }
 
@@ -39,44 +96,244 @@ func CreateRouter(conf *config.Config) (h *Handler, cleanup func()) {
 	h.Routes()
 
 	// Router setup
-	h.Router.PanicHandler = middleware.PanicHandler(h.Handler)
-	h.Router.NotFound = middleware.NotFound(h.Handler)
+	SetupRouter(h)
 
 	// Middleware
 	SetupMiddleware(h)
 
+	// Background tasks must stop on shutdown
+	go h.WatchClientVersion(5 * time.Second)
+
 	return h, h.Cleanup
 }
 
+// CreateMockRouter registers mock routes for the OpenAPI document at path,
+// instead of the service routes. Middleware is the same as CreateRouter
+func CreateMockRouter(conf *config.Config, path string) (
+	h *Handler, cleanup func(), err error) {
+
+	doc, err := openapi.Load(path)
+	if err != nil {
+		return h, cleanup, err
+	}
+
+	h = NewHandler(conf)
+	err = h.Mock(doc)
+	if err != nil {
+		h.Cleanup()
+		return h, cleanup, err
+	}
+
+	SetupRouter(h)
+
+	SetupMiddleware(h)
+
+	return h, h.Cleanup, nil
+}
+
 func (h *Handler) Routes() {
-	// Routes
+	// Routes, see h.Registry
 
 	// Index page requires special routes
-	h.Router.HandlerFunc("GET", "/", h.Index)
-	h.Router.HandlerFunc("GET", "/index.html", h.Index)
-	h.Router.HandlerFunc("GET", "/favicon.ico", h.Favicon)
+	h.HandlerFunc("GET", "/", h.Index, &handler.RouteOptions{
+		Name: "index", Description: "Index page",
+		Tags: []string{"www"}, Policy: share.PolicyPublic,
+	})
+	h.HandlerFunc("GET", "/index.html", h.Index, &handler.RouteOptions{
+		Name: "index.html", Description: "Index page",
+		Tags: []string{"www"}, Policy: share.PolicyPublic,
+	})
+	h.HandlerFunc("GET", "/favicon.ico", h.Favicon, &handler.RouteOptions{
+		Name: "favicon", Tags: []string{"www"}, Policy: share.PolicyPublic,
+	})
 
 	// Misc
-	h.Router.HandlerFunc("GET", "/api", h.API)
-	h.Router.HandlerFunc("POST", "/api", h.API)
-	h.Router.HandlerFunc("GET", "/panic", h.Panic)
-	h.Router.HandlerFunc("GET", "/hello/:name", h.Hello)
+	h.HandlerFunc("GET", "/api", h.Versions(map[int]http.HandlerFunc{
+		1: h.API,
+		2: h.APIv2,
+	}), &handler.RouteOptions{
+		Name: "api", Description: "Welcome message",
+		Tags: []string{"api"}, Response: share.Response{},
+	})
+	apiRequest := h.LoadSchema("api.request.json")
+	apiResponse := h.LoadSchema("api.response.json")
+	h.HandlerFunc("POST", "/api", h.Schema(h.API, &handler.SchemaOptions{
+		Request:  apiRequest,
+		Response: apiResponse,
+	}), &handler.RouteOptions{
+		Name: "api.post", Description: "Validate the request body",
+		Tags:          []string{"api"},
+		RequestSchema: apiRequest, ResponseSchema: apiResponse,
+	})
+	h.HandlerFunc("GET", "/panic", h.Panic, &handler.RouteOptions{
+		Name: "panic", Description: "Recover from a panic",
+		Tags: []string{"example"}, Policy: share.PolicyPublic,
+	})
+	h.HandlerFunc("GET", "/hello/:name", h.Hello, &handler.RouteOptions{
+		Name: "hello", Description: "Greeting for name",
+		Tags: []string{"example"}, Request: share.HelloRequest{},
+	})
+	h.HandlerFunc("GET", "/items", h.Items, &handler.RouteOptions{
+		Name: "items", Description: "List a page of items",
+		Tags: []string{"example"}, Request: share.PageRequest{},
+		Response: share.PageResponse{Items: []share.Response{}},
+	})
+	h.HandlerFunc("GET", "/stream", h.StreamExample, &handler.RouteOptions{
+		Name: "stream", Description: "Stream a page of items",
+		Tags: []string{"example"},
+		Request: struct {
+			share.StreamRequest
+			share.PageRequest
+		}{},
+	})
+	h.HandlerFunc("POST", "/upload", h.Upload(h.UploadExample,
+		&handler.UploadOptions{
+			MaxFileBytes: 10 * int64(units.MiB),
+			MaxFiles:     5,
+			ContentTypes: []string{"image/*", "text/plain", "application/pdf"},
+		}), &handler.RouteOptions{
+		Name: "upload", Description: "Upload files",
+		Tags: []string{"example"}, Response: share.UploadResponse{},
+		// Limits are set by UploadOptions
+		SkipMaxBytes: true,
+	})
+	h.WebSocket("/echo", h.Echo, &handler.WebSocketOptions{
+		Route: &handler.RouteOptions{
+			Name: "echo", Description: "Echo WebSocket messages",
+			Tags: []string{"example"},
+		},
+	})
 
 	// Static content
-	h.Router.ServeFiles("/www/*filepath", http.Dir(
-		filepath.Join(h.Config.Dir(), "www")))
+	h.ServeStatic(h.Assets)
 
 	// Client
-	h.Router.HandlerFunc("GET", "/client/download", h.ClientDownload)
-	h.Router.HandlerFunc("GET", "/client/version", h.ClientVersion)
+	client := h.Group("/client")
+	client.HandlerFunc("GET", "/version", h.ClientVersion, &handler.RouteOptions{
+		Name: "client.version", Description: "Latest client version",
+		Tags: []string{"client"}, Response: share.ClientVersion{},
+	})
+	client.HandlerFunc("GET", "/events", h.ClientEventStream, &handler.RouteOptions{
+		Name: "client.events", Description: "Client version events",
+		Tags: []string{"client"},
+	})
+	// Byte offsets of ranges refer to the uncompressed file
+	download := client.Group("/download", func(next http.Handler) http.Handler {
+		return middleware.NoCompression(next.ServeHTTP)
+	})
+	download.HandlerFunc("GET", "", h.ClientDownload, &handler.RouteOptions{
+		Name: "client.download", Description: "Download the latest client",
+		Tags: []string{"client"},
+	})
+	download.HandlerFunc("HEAD", "", h.ClientDownload, &handler.RouteOptions{
+		Name: "client.download.head", Tags: []string{"client"},
+	})
+
+	// Admin
+	admin := h.Group("/admin")
+	admin.HandlerFunc("GET", "/routes", h.ListRoutes, &handler.RouteOptions{
+		Name: "admin.routes", Description: "List registered routes",
+		Tags: []string{"admin"}, Response: share.RoutesResponse{},
+	})
+
+	// Docs
+	h.HandlerFunc("GET", "/openapi.json", h.Docs(&handler.DocsOptions{
+		Title:       h.Config.Name(),
+		Version:     h.Config.Version(),
+		Description: h.VersionDocs(),
+		SecuritySchemes: map[string]*openapi.SecurityScheme{
+			share.PolicyToken: {
+				Type: "apiKey", In: "query", Name: "token",
+				Description: "Token is required by default",
+			},
+		},
+	}), &handler.RouteOptions{
+		Name: "openapi", Description: "OpenAPI document",
+		Tags: []string{"docs"}, Policy: share.PolicyPublic,
+	})
+	h.HandlerFunc("GET", "/docs", h.APIDocs, &handler.RouteOptions{
+		Name: "docs", Description: "API docs",
+		Tags: []string{"docs"}, Policy: share.PolicyPublic,
+	})
+}
+
+// APIVersions are the supported versions of the API.
+// Version 1 is the default for clients built before versioning,
+// set the Deprecated and Sunset dates after the default changes
+var APIVersions = []middleware.APIVersion{
+	{Version: 1},
+	{Version: 2},
+}
+
+// VersionPrefixes of versioned routes, other routes are not versioned
+var VersionPrefixes = []string{"/api", "/client"}
+
+// DefaultAPIVersion is used for requests that don't specify a version,
+// clients built before versioning don't send one
+const DefaultAPIVersion = 1
+
+// MediaType for the Accept header version parameter
+func (h *Handler) MediaType() string {
+	return fmt.Sprintf("application/vnd.%s+json", h.Config.Name())
+}
+
+// VersionDocs describes how to select the API version,
+// for the OpenAPI document
+func (h *Handler) VersionDocs() string {
+	b := strings.Builder{}
+	b.WriteString("Routes under ")
+	for i, prefix := range VersionPrefixes {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(fmt.Sprintf("`%s`", prefix))
+	}
+	b.WriteString(" are versioned. ")
+	b.WriteString("Select the version with a path prefix, e.g. `/v2/api`, ")
+	b.WriteString(fmt.Sprintf("the `%s` header, ", share.HeaderAPIVersion))
+	b.WriteString(fmt.Sprintf(
+		"or the version parameter of the Accept header, e.g. `%s; version=2`. ",
+		h.MediaType()))
+	b.WriteString(fmt.Sprintf(
+		"Requests without a version get version %d.\n\n", DefaultAPIVersion))
+	for _, v := range APIVersions {
+		b.WriteString(fmt.Sprintf("- Version %d", v.Version))
+		if !v.Deprecated.IsZero() {
+			b.WriteString(fmt.Sprintf(", deprecated %s",
+				v.Deprecated.Format("2006-01-02")))
+		}
+		if !v.Sunset.IsZero() {
+			b.WriteString(fmt.Sprintf(", sunset %s",
+				v.Sunset.Format("2006-01-02")))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// SetupRouter sets JSON handlers for router errors,
+// and automatic replies to OPTIONS requests
+func SetupRouter(h *Handler) {
+	h.Router.PanicHandler = middleware.PanicHandler(h.Handler)
+	h.Router.NotFound = middleware.NotFound(h.Handler)
+	h.Router.MethodNotAllowed = middleware.MethodNotAllowed(h.Handler)
+	h.Router.GlobalOPTIONS = http.HandlerFunc(middleware.GlobalOPTIONS)
 }
 
 // SetupMiddleware configures the middleware given a route handler
 func SetupMiddleware(h *Handler) {
 	// Middleware
 	var httpHandler http.Handler = h.Router
-	// WARNING Allows all origins
-	httpHandler = cors.Default().Handler(h.Router)
+	// WARNING Allows all origins.
+	// Preflight requests are passed on to the router,
+	// that replies with the allowed methods for the path, see SetupRouter
+	httpHandler = cors.New(cors.Options{
+		AllowedMethods: []string{
+			http.MethodGet, http.MethodHead, http.MethodPost,
+			http.MethodPut, http.MethodPatch, http.MethodDelete,
+		},
+		OptionsPassthrough: true,
+	}).Handler(h.Router)
 	maxBytes, err := h.Config.FnMaxBytesKb().Int64()
 	if err != nil {
 		log.Error().Stack().Err(err).Msg("")
@@ -84,37 +341,100 @@ func SetupMiddleware(h *Handler) {
 	}
 	httpHandler = middleware.MaxBytes(httpHandler, &middleware.MaxBytesOptions{
 		MaxBytes: maxBytes * int64(units.KiB),
+		Skipper:  h.Registry.SkipMaxBytes,
+	})
+	// Limit applies to the decompressed body
+	httpHandler = middleware.Decompress(httpHandler, &middleware.DecompressOptions{
+		H: h.Handler,
 	})
 	httpHandler = middleware.LogRequest(httpHandler)
 	httpHandler = middleware.Logger(httpHandler)
 	httpHandler = middleware.Auth(httpHandler, &middleware.AuthOptions{
-		Skipper: middleware.AuthSkipper,
+		// Routes with the public policy
+		Skipper: h.Registry.Public,
+	})
+	httpHandler = middleware.Compress(httpHandler, &middleware.CompressOptions{
+		Skipper: middleware.CompressSkipper,
+	})
+	// Skippers read the route from the request context
+	httpHandler = middleware.Route(httpHandler, &middleware.RouteOptions{
+		Registry: h.Registry,
+	})
+	// Before auth, the version path prefix must be removed for the skipper
+	httpHandler = middleware.Version(httpHandler, &middleware.VersionOptions{
+		H:         h.Handler,
+		Prefixes:  VersionPrefixes,
+		Versions:  APIVersions,
+		Default:   DefaultAPIVersion,
+		MediaType: h.MediaType(),
 	})
-	httpHandler = gziphandler.GzipHandler(httpHandler)
 	httpHandler = middleware.RequestID(httpHandler)
 
 	h.HTTPHandler = httpHandler
 }
 
-func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
-	f, err := os.Open(filepath.Join(h.Config.Dir(), "www", "index.html"))
+// FS returns the embedded files, so the binary can be deployed on its own.
+// If APP_DEV is true the dir on disk is used instead,
+// then changes are visible without building again
+func (h *Handler) FS(embedded fs.FS, dir string) fs.FS {
+	if h.Dev() {
+		return os.DirFS(filepath.Join(h.Config.Dir(), dir))
+	}
+	return embedded
+}
+
+// PaginatorSecret signs cursors, so they are valid after a restart
+// and on other instances. Exits if it's not set
+func (h *Handler) PaginatorSecret() []byte {
+	secret := h.Config.PaginatorSecret()
+	if secret == "" {
+		err := errors.Errorf("APP_PAGINATOR_SECRET is not set")
+		log.Error().Stack().Err(err).Msg("")
+		os.Exit(1)
+	}
+	return []byte(secret)
+}
+
+// LoadSchema from the schema dir, exits on error
+func (h *Handler) LoadSchema(name string) *schema.Schema {
+	s, err := schema.LoadFS(h.Schemas, name)
 	if err != nil {
-		h.JSON(http.StatusInternalServerError, w, r,
-			errors.WithStack(err))
-		return
+		log.Error().Stack().Err(err).Msg("")
+		os.Exit(1)
 	}
-	b, err := ioutil.ReadAll(f)
+	return s
+}
+
+// LoadTemplates from the www dir, exits on error.
+// Templates are parsed again on change if APP_DEV is true
+func (h *Handler) LoadTemplates() *handler.Renderer {
+	rr, err := handler.NewRenderer(&handler.RendererOptions{
+		FS:     h.WWW,
+		Reload: h.Dev(),
+		Funcs: template.FuncMap{
+			// Fingerprinted URL for static files
+			"asset": h.Assets.URL,
+		},
+	})
 	if err != nil {
-		h.JSON(http.StatusInternalServerError, w, r,
-			errors.WithStack(err))
-		return
+		log.Error().Stack().Err(err).Msg("")
+		os.Exit(1)
 	}
-	h.Write(http.StatusOK, "", w, r, b)
+	return rr
+}
+
+func (h *Handler) Index(w http.ResponseWriter, r *http.Request) {
+	h.HTML(http.StatusOK, w, r, "index.html", nil)
+}
+
+// APIDocs renders the OpenAPI document with Redoc
+func (h *Handler) APIDocs(w http.ResponseWriter, r *http.Request) {
+	h.HTML(http.StatusOK, w, r, "docs.html", nil)
 }
 
 func (h *Handler) Favicon(w http.ResponseWriter, r *http.Request) {
-	faviconPath := filepath.Join(h.Config.Dir(), "www", "favicon.ico")
-	http.ServeFile(w, r, faviconPath)
+	// Request path matches the file name
+	http.FileServer(http.FS(h.WWW)).ServeHTTP(w, r)
 }
 
 func (h *Handler) API(w http.ResponseWriter, r *http.Request) {
@@ -142,36 +462,134 @@ func (h *Handler) API(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ClientVersion prints the latest client version
+// APIv2 also returns the version
+func (h *Handler) APIv2(w http.ResponseWriter, r *http.Request) {
+	version, _ := r.Context().Value(share.ContextAPIVersion).(int)
+	h.JSON(http.StatusOK, w, r, share.WelcomeResponse{
+		Message: "Welcome",
+		Version: version,
+	})
+}
+
+// ClientVersion prints the latest client version.
+// Clients poll this route, use conditional requests to avoid sending
+// the body if it did not change
 func (h *Handler) ClientVersion(w http.ResponseWriter, r *http.Request) {
-	f, err := os.Open(filepath.Join(h.Config.Dir(), "dist", "client.json"))
+	clientVersion, modTime, err := h.LatestClientVersion()
 	if err != nil {
-		h.JSON(http.StatusInternalServerError, w, r,
-			errors.WithStack(err))
+		h.JSON(http.StatusInternalServerError, w, r, err)
 		return
 	}
-	b, err := ioutil.ReadAll(f)
+
+	h.JSONWithOptions(http.StatusOK, w, r, clientVersion,
+		&handler.ResponseOptions{
+			ETag:         handler.ETagStrong,
+			LastModified: modTime,
+		})
+}
+
+// LatestClientVersion from dist/client.json,
+// the file is only read again if it was modified
+func (h *Handler) LatestClientVersion() (
+	clientVersion share.ClientVersion, modTime time.Time, err error) {
+
+	versionPath := filepath.Join(h.Config.Dir(), "dist", "client.json")
+	fi, err := os.Stat(versionPath)
 	if err != nil {
-		h.JSON(http.StatusInternalServerError, w, r,
-			errors.WithStack(err))
-		return
+		return clientVersion, modTime, errors.WithStack(err)
 	}
 
-	var clientVersion share.ClientVersion
+	h.clientVersionMu.Lock()
+	defer h.clientVersionMu.Unlock()
+	if fi.ModTime().Equal(h.clientVersionModTime) {
+		return h.clientVersion, h.clientVersionModTime, nil
+	}
+
+	b, err := ioutil.ReadFile(versionPath)
+	if err != nil {
+		return clientVersion, modTime, errors.WithStack(err)
+	}
 	err = json.Unmarshal(b, &clientVersion)
 	if err != nil {
-		h.JSON(http.StatusInternalServerError, w, r,
-			errors.WithStack(err))
-		return
+		return clientVersion, modTime, errors.WithStack(err)
 	}
 
-	h.JSON(http.StatusOK, w, r, clientVersion)
+	h.clientVersion = clientVersion
+	h.clientVersionModTime = fi.ModTime()
+	return h.clientVersion, h.clientVersionModTime, nil
+}
+
+// ClientEventStream pushes client events, see WatchClientVersion
+func (h *Handler) ClientEventStream(w http.ResponseWriter, r *http.Request) {
+	h.SSE(w, r, h.ClientEvents, nil)
+}
+
+// WatchClientVersion publishes a client_version event when a new client
+// is published to the dist dir, see scripts/build-client.sh
+func (h *Handler) WatchClientVersion(interval time.Duration) {
+	var modTime time.Time
+	_, modTime, _ = h.LatestClientVersion()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.Done():
+			return
+
+		case <-ticker.C:
+			clientVersion, latest, err := h.LatestClientVersion()
+			if err != nil {
+				if !os.IsNotExist(errors.Cause(err)) {
+					log.Error().Stack().Err(err).Msg("")
+				}
+				continue
+			}
+			if !latest.After(modTime) {
+				continue
+			}
+			modTime = latest
+			h.ClientEvents.Publish(share.EventClientVersion, clientVersion)
+			log.Info().Str("version", clientVersion.Version).
+				Msg("client version published")
+		}
+	}
 }
 
-// ClientDownload serves the latest client
+// ClientDownload serves the latest client.
+// Interrupted downloads are resumed with Range requests,
+// If-Range makes sure the parts are from the same build
 func (h *Handler) ClientDownload(w http.ResponseWriter, r *http.Request) {
 	clientPath := filepath.Join(h.Config.Dir(), "dist", "client")
-	http.ServeFile(w, r, clientPath)
+	f, err := os.Open(clientPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			h.JSON(http.StatusNotFound, w, r, errors.Errorf("client not found"))
+			return
+		}
+		h.JSON(http.StatusInternalServerError, w, r, errors.WithStack(err))
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		h.JSON(http.StatusInternalServerError, w, r, errors.WithStack(err))
+		return
+	}
+
+	// The checksum is a strong validator, but only if it was written
+	// after the client was built, see scripts/build-client.sh
+	clientVersion, modTime, err := h.LatestClientVersion()
+	if err == nil && clientVersion.Checksum != "" &&
+		!modTime.Before(fi.ModTime()) {
+		w.Header().Set("ETag", fmt.Sprintf("%q", clientVersion.Checksum))
+	}
+	w.Header().Set("Cache-Control", handler.CacheRevalidate)
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	// Handles Range, If-Range, and conditional requests,
+	// responds with 206 Partial Content or 416 Range Not Satisfiable
+	http.ServeContent(w, r, "client", fi.ModTime(), f)
 }
 
 func (h *Handler) Panic(w http.ResponseWriter, r *http.Request) {
@@ -179,9 +597,122 @@ func (h *Handler) Panic(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) Hello(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
+	var req share.HelloRequest
+	err := h.Bind(r, &req)
+	if err != nil {
+		h.JSON(handler.BindStatus(err), w, r, err)
+		return
+	}
+	if req.Greeting == "" {
+		req.Greeting = "hello"
+	}
 	h.Write(http.StatusOK, "", w, r,
-		[]byte(fmt.Sprintf("hello, %s!\n", params.ByName("name"))))
+		[]byte(fmt.Sprintf("%s, %s!\n", req.Greeting, req.Name)))
+}
+
+// exampleItems is the number of items listed by the example routes
+const exampleItems = 1000
+
+// examplePage returns the offset for the page,
+// and the position of the next page
+func examplePage(page handler.Page) (offset int, next string, err error) {
+	if page.Position != "" {
+		offset, err = strconv.Atoi(page.Position)
+		if err != nil {
+			return 0, "", errors.WithStack(err)
+		}
+	}
+	end := offset + page.Limit
+	if end < exampleItems {
+		next = strconv.Itoa(end)
+	}
+	return offset, next, nil
+}
+
+// Items lists a page of items
+func (h *Handler) Items(w http.ResponseWriter, r *http.Request) {
+	page, err := h.Paginator.Parse(r)
+	if err != nil {
+		h.JSON(http.StatusUnprocessableEntity, w, r, err)
+		return
+	}
+	offset, next, err := examplePage(page)
+	if err != nil {
+		h.JSON(http.StatusInternalServerError, w, r, err)
+		return
+	}
+
+	items := []share.Response{}
+	for i := offset; i < offset+page.Limit && i < exampleItems; i++ {
+		items = append(items, share.Response{
+			Message: fmt.Sprintf("item %d", i+1)})
+	}
+	h.JSON(http.StatusOK, w, r, h.Paginator.Response(w, r, page, items, next))
+}
+
+// StreamExample streams a page of items without buffering the response
+func (h *Handler) StreamExample(w http.ResponseWriter, r *http.Request) {
+	req := share.StreamRequest{}
+	err := h.Bind(r, &req)
+	if err != nil {
+		h.JSON(handler.BindStatus(err), w, r, err)
+		return
+	}
+	page, err := h.StreamPaginator.Parse(r)
+	if err != nil {
+		h.JSON(http.StatusUnprocessableEntity, w, r, err)
+		return
+	}
+	offset, next, err := examplePage(page)
+	if err != nil {
+		h.JSON(http.StatusInternalServerError, w, r, err)
+		return
+	}
+
+	// Links must be set before streaming starts
+	h.StreamPaginator.SetLinks(w, r, page, next)
+	i := offset
+	h.Stream(http.StatusOK, w, r, handler.IteratorFunc(
+		func(ctx context.Context) (interface{}, error) {
+			if i >= offset+page.Limit || i >= exampleItems {
+				return nil, io.EOF
+			}
+			i++
+			return share.Response{Message: fmt.Sprintf("item %d", i)}, nil
+		}), &handler.StreamOptions{Format: req.Format})
+}
+
+// UploadExample lists the files received,
+// temp files are removed after the response is written
+func (h *Handler) UploadExample(
+	w http.ResponseWriter, r *http.Request, u *handler.Upload) {
+
+	resp := share.UploadResponse{Files: []share.UploadedFile{}}
+	for _, f := range u.Files {
+		resp.Files = append(resp.Files, f.UploadedFile)
+	}
+	h.JSON(http.StatusOK, w, r, resp)
+}
+
+// Echo messages received on the WebSocket connection
+func (h *Handler) Echo(conn *websocket.Conn, r *http.Request) {
+	for {
+		messageType, b, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err,
+				websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Ctx(r.Context()).Error().Stack().
+					Err(errors.WithStack(err)).Msg("")
+			}
+			return
+		}
+		err = conn.WriteMessage(messageType, b)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Stack().
+				Err(errors.WithStack(err)).Msg("")
+			return
+		}
+	}
 }
 
 func (h *Handler) NotImplemented(w http.ResponseWriter, r *http.Request) {
@@ -3,14 +3,18 @@ package main
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/alecthomas/units"
 	"github.com/mozey/httprouter-util/internal/app"
 	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/middleware"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
@@ -18,7 +22,29 @@ import (
 func main() {
 	conf := config.New()
 
-	h, cleanup := app.CreateRouter(conf)
+	if len(os.Args) > 1 && os.Args[1] == "routes" {
+		printRoutes(conf)
+		os.Exit(0)
+	}
+
+	var h *app.Handler
+	var cleanup func()
+	if len(os.Args) > 1 && os.Args[1] == "mock" {
+		// Mock server for the OpenAPI document, e.g.
+		//	go run main.go mock openapi.json
+		if len(os.Args) < 3 {
+			fmt.Println("usage: mock <openapi.json>")
+			os.Exit(1)
+		}
+		var err error
+		h, cleanup, err = app.CreateMockRouter(conf, os.Args[2])
+		if err != nil {
+			log.Error().Stack().Err(err).Msg("")
+			os.Exit(1)
+		}
+	} else {
+		h, cleanup = app.CreateRouter(conf)
+	}
 	defer cleanup()
 
 	// Header to make app reloads more visible
@@ -43,11 +69,16 @@ func main() {
 	}
 	srv.MaxHeaderBytes = int(maxBytes * int64(units.KiB))
 
+	// Close event streams and WebSockets when shutdown starts,
+	// otherwise Shutdown waits for them until the context times out
+	srv.RegisterOnShutdown(h.Shutdown)
+
 	shutdown := make(chan struct{})
 	go func() {
 		// "Shutdown gracefully shuts down the server without
 		//	interrupting any active connections...
-		// 	does not attempt to close nor wait for... WebSockets"
+		// 	does not attempt to close nor wait for... WebSockets".
+		// WebSockets are closed by h.Shutdown, and waited for with h.Wait
 		// https://golang.org/pkg/net/http/#Server.Shutdown
 		sigint := make(chan os.Signal, 1)
 		signal.Notify(sigint, os.Interrupt)
@@ -55,18 +86,34 @@ func main() {
 		log.Info().Msg("ctrl+c interrupt, shutting down...")
 
 		// Interrupt signal received, shut down.
-		err := srv.Shutdown(context.Background())
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err := srv.Shutdown(ctx)
 		if err != nil {
 			// Error from closing listeners, or context timeout
 			log.Error().Stack().Err(err).Msg("")
 			os.Exit(1)
 		}
+		err = h.Wait(ctx)
+		if err != nil {
+			log.Error().Stack().Err(err).Msg("")
+			os.Exit(1)
+		}
 
 		close(shutdown)
 	}()
 
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Error().Stack().Err(errors.WithStack(err)).Msg("")
+		os.Exit(1)
+	}
+	// JSON responses for errors that net/http handles without calling
+	// the handler, e.g. MaxHeaderBytes and ReadTimeout
+	listener = middleware.Listener(&srv, listener)
+
 	log.Info().Msgf("listening on %s", h.Config.Addr())
-	err = errors.WithStack(srv.ListenAndServe())
+	err = errors.WithStack(srv.Serve(listener))
 	if err.Error() != http.ErrServerClosed.Error() {
 		log.Error().Stack().Err(err).Msg("")
 		os.Exit(1)
@@ -75,3 +122,21 @@ func main() {
 	log.Info().Msg("bye!")
 	os.Exit(0)
 }
+
+// printRoutes lists the routes in the registry, e.g.
+//
+//	go run main.go routes
+func printRoutes(conf *config.Config) {
+	h := app.NewHandler(conf)
+	h.Routes()
+	defer h.Cleanup()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATTERN\tNAME\tPOLICY\tTAGS\tDESCRIPTION")
+	for _, route := range h.Registry.Routes() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			route.Method, route.Pattern, route.Name, route.Policy,
+			strings.Join(route.Tags, ","), route.Description)
+	}
+	_ = w.Flush()
+}
@@ -1,8 +1,10 @@
 package main_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/alecthomas/units"
+	"github.com/mozey/httprouter-util/pkg/share"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
 	"math/rand"
@@ -45,4 +47,11 @@ func TestMaxHeaderBytes(t *testing.T) {
 	fmt.Println("body: ", string(body))
 
 	require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, resp.StatusCode)
+
+	// JSON body is written by middleware.Listener
+	errResp := share.ErrResponse{}
+	err = json.Unmarshal(body, &errResp)
+	require.NoError(t, err)
+	require.NotEmpty(t, errResp.RequestID)
+	require.Equal(t, errResp.RequestID, resp.Header.Get(share.HeaderXRequestID))
 }
@@ -25,22 +25,24 @@ func NewHandler(conf *config.Config) (c *Client) {
 	return c
 }
 
+// DoUpdate downloads the client, see Download, and replaces the executable.
 // https://github.com/inconshreveable/go-update
 func (c *Client) DoUpdate(token, checksumHex string) error {
-	checksumHex = strings.ReplaceAll(checksumHex, "\n", "")
+	checksumHex = strings.ToLower(strings.TrimSpace(checksumHex))
 	checksumBytes, err := hex.DecodeString(checksumHex)
 	if err != nil {
 		return errors.WithStack(err)
 	}
 
-	resp, err := http.Get(c.Config.ExecTemplateClientDownloadUrl(token))
+	f, err := c.Download(token, checksumHex)
 	if err != nil {
 		return err
 	}
 	defer (func() {
-		_ = resp.Body.Close()
+		_ = f.Close()
+		_ = os.Remove(f.Name())
 	})()
-	err = update.Apply(resp.Body, update.Options{
+	err = update.Apply(f, update.Options{
 		Hash:       crypto.SHA256,
 		Checksum:   checksumBytes,
 		TargetMode: os.FileMode(0755),
@@ -0,0 +1,163 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// downloadAttempts is the number of times an interrupted download is resumed
+const downloadAttempts = 5
+
+// downloadPath for the partial download, the name includes the checksum,
+// so a download is only resumed for the same build
+func downloadPath(checksumHex string) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("client-%s.download", checksumHex))
+}
+
+// Download the latest client to a temp file and verify the SHA-256 checksum.
+// Interrupted downloads are resumed with Range requests,
+// also across calls, until the checksum matches.
+// The caller must close and remove the file
+func (c *Client) Download(token, checksumHex string) (f *os.File, err error) {
+	f, err = os.OpenFile(
+		downloadPath(checksumHex), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = c.download(token, checksumHex, f)
+		if err == nil {
+			err = verify(f, checksumHex)
+			if err == nil {
+				_, err = f.Seek(0, io.SeekStart)
+				return f, errors.WithStack(err)
+			}
+			// Start again, the partial file is from another build
+			terr := f.Truncate(0)
+			if terr != nil {
+				_ = f.Close()
+				return nil, errors.WithStack(terr)
+			}
+		}
+		if _, ok := err.(downloadError); ok || attempt == downloadAttempts {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+			return nil, err
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+// downloadError is not retried
+type downloadError struct {
+	err error
+}
+
+func (e downloadError) Error() string {
+	return e.err.Error()
+}
+
+// download appends the remainder of the client to f
+func (c *Client) download(token, checksumHex string, f *os.File) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return downloadError{errors.WithStack(err)}
+	}
+	offset := fi.Size()
+
+	req, err := http.NewRequest(
+		"GET", c.Config.ExecTemplateClientDownloadUrl(token), nil)
+	if err != nil {
+		return downloadError{errors.WithStack(err)}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// The server sets the checksum as the ETag,
+		// if the build changed the whole file is sent
+		req.Header.Set("If-Range", fmt.Sprintf("%q", checksumHex))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer (func() {
+		_ = resp.Body.Close()
+	})()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0
+	case http.StatusPartialContent:
+		start, err := contentRangeStart(resp.Header.Get("Content-Range"))
+		if err != nil {
+			return downloadError{err}
+		}
+		if start != offset {
+			return downloadError{errors.Errorf(
+				"range starts at %d, expected %d", start, offset)}
+		}
+	case http.StatusRequestedRangeNotSatisfiable:
+		// Already downloaded, the checksum is verified by the caller
+		return nil
+	default:
+		return downloadError{errors.Errorf(
+			"%v %s", resp.StatusCode, http.StatusText(resp.StatusCode))}
+	}
+
+	err = f.Truncate(offset)
+	if err != nil {
+		return downloadError{errors.WithStack(err)}
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return downloadError{errors.WithStack(err)}
+	}
+	// Bytes received before an error are kept
+	_, err = io.Copy(f, resp.Body)
+	return errors.WithStack(err)
+}
+
+// contentRangeStart parses the first byte position,
+// e.g. "bytes 100-199/200"
+func contentRangeStart(contentRange string) (int64, error) {
+	s := strings.TrimPrefix(contentRange, "bytes ")
+	i := strings.Index(s, "-")
+	if s == contentRange || i < 0 {
+		return 0, errors.Errorf("invalid content range %s", contentRange)
+	}
+	start, err := strconv.ParseInt(s[:i], 10, 64)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return start, nil
+}
+
+// verify the SHA-256 checksum of f
+func verify(f *os.File, checksumHex string) error {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return downloadError{errors.WithStack(err)}
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return downloadError{errors.WithStack(err)}
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if sum != checksumHex {
+		return errors.Errorf("checksum %s does not match %s", sum, checksumHex)
+	}
+	return nil
+}
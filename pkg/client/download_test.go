@@ -0,0 +1,62 @@
+package client_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/client"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownload(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+
+	content := strings.Repeat("0123456789", 1000)
+	sum := sha256.Sum256([]byte(content))
+	checksum := hex.EncodeToString(sum[:])
+
+	// The first response is interrupted halfway
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "123", r.URL.Query().Get("token"))
+			ranges = append(ranges, r.Header.Get("Range"))
+			if len(ranges) == 1 {
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				_, _ = w.Write([]byte(content[:len(content)/2]))
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}
+			require.Equal(t, fmt.Sprintf("%q", checksum), r.Header.Get("If-Range"))
+			w.Header().Set("ETag", fmt.Sprintf("%q", checksum))
+			http.ServeContent(w, r, "client", time.Time{},
+				strings.NewReader(content))
+		}))
+	defer srv.Close()
+	conf.SetTemplateClientDownloadUrl(srv.URL + "?token={{.Token}}")
+
+	c := client.NewHandler(conf)
+	f, err := c.Download("123", checksum)
+	require.NoError(t, err)
+	defer (func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	})()
+
+	require.Equal(t, []string{"", fmt.Sprintf("bytes=%d-", len(content)/2)},
+		ranges)
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.Equal(t, content, string(b))
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"bufio"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Event received from the server event stream
+type Event struct {
+	ID   string
+	Name string
+	Data string
+}
+
+// maxWatchBackoff limits the delay between failed connection attempts
+const maxWatchBackoff = time.Minute
+
+// Watch the server event stream and call fn for each event.
+// Reconnects with the Last-Event-ID header if the stream ends.
+// Failed connections, and responses like 503 Service Unavailable, are
+// retried with exponential backoff, respecting the Retry-After header.
+// Returns if fn returns an error, or the server rejects the request, e.g. 401
+func (c *Client) Watch(token string, fn func(e Event) error) error {
+	lastEventID := ""
+	retry := 3 * time.Second
+	failures := 0
+	for {
+		err := c.watch(token, &lastEventID, &retry, fn)
+		if werr, ok := err.(watchError); ok {
+			return werr.err
+		}
+		cerr, ok := err.(connectError)
+		if !ok {
+			// Stream ended, reconnect
+			failures = 0
+			time.Sleep(retry)
+			continue
+		}
+		failures++
+		delay := watchBackoff(retry, failures)
+		if cerr.retryAfter > delay {
+			delay = cerr.retryAfter
+		}
+		time.Sleep(delay)
+	}
+}
+
+// watchBackoff doubles the retry delay for each failure
+func watchBackoff(retry time.Duration, failures int) time.Duration {
+	delay := retry
+	for i := 1; i < failures && delay < maxWatchBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxWatchBackoff {
+		delay = maxWatchBackoff
+	}
+	return delay
+}
+
+// watchError is returned by fn, and stops the watch
+type watchError struct {
+	err error
+}
+
+func (e watchError) Error() string {
+	return e.err.Error()
+}
+
+// connectError is returned if the stream could not be opened,
+// the watch is retried with backoff
+type connectError struct {
+	err error
+	// retryAfter is the delay requested by the server
+	retryAfter time.Duration
+}
+
+func (e connectError) Error() string {
+	return e.err.Error()
+}
+
+func (c *Client) watch(token string, lastEventID *string,
+	retry *time.Duration, fn func(e Event) error) error {
+
+	req, err := http.NewRequest(
+		"GET", c.Config.ExecTemplateClientEventsUrl(token), nil)
+	if err != nil {
+		return watchError{errors.WithStack(err)}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return connectError{err: errors.WithStack(err)}
+	}
+	defer (func() {
+		_ = resp.Body.Close()
+	})()
+
+	if resp.StatusCode != http.StatusOK {
+		err = errors.Errorf(
+			"%v %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+		switch resp.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests,
+			http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			// Temporary
+			retryAfter, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			return connectError{
+				err:        err,
+				retryAfter: time.Duration(retryAfter) * time.Second,
+			}
+		}
+		return watchError{err}
+	}
+
+	// Parse the text/event-stream format,
+	// https://html.spec.whatwg.org/multipage/server-sent-events.html
+	e := Event{}
+	var data []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// Dispatch
+			if len(data) > 0 {
+				e.Data = strings.Join(data, "\n")
+				if e.ID != "" {
+					*lastEventID = e.ID
+				}
+				err = fn(e)
+				if err != nil {
+					return watchError{err}
+				}
+			}
+			e = Event{}
+			data = nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			// Comment, e.g. heartbeat
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "id":
+			e.ID = value
+		case "event":
+			e.Name = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			ms, err := strconv.Atoi(value)
+			if err == nil {
+				*retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return errors.WithStack(scanner.Err())
+}
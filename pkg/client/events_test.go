@@ -0,0 +1,55 @@
+package client_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/client"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+
+	// Unavailable at first, then the stream ends after one event
+	var lastEventIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+			switch len(lastEventIDs) {
+			case 1:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			case 4:
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprintf(w, "retry: 10\n\nid: a-%d\ndata: foo\n\n",
+				len(lastEventIDs))
+		}))
+	defer srv.Close()
+	conf.SetTemplateClientEventsUrl(srv.URL + "?token={{.Token}}")
+
+	c := client.NewHandler(conf)
+	events := []client.Event{}
+	err = c.Watch("123", func(e client.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	require.EqualError(t, err, "401 Unauthorized")
+	require.Equal(t, []string{"", "", "a-2", "a-3"}, lastEventIDs)
+	require.Len(t, events, 2)
+
+	// Stopped by fn
+	lastEventIDs = []string{"", ""}
+	err = c.Watch("123", func(e client.Event) error {
+		return errors.Errorf("stop")
+	})
+	require.EqualError(t, err, "stop")
+}
@@ -15,6 +15,9 @@ import (
 // APP_ADDR
 var addr string
 
+// APP_DEV
+var dev string
+
 // APP_EXE
 var exe string
 
@@ -27,9 +30,15 @@ var maxPayloadMb string
 // APP_NAME
 var name string
 
+// APP_PAGINATOR_SECRET
+var paginatorSecret string
+
 // APP_TEMPLATE_CLIENT_DOWNLOAD_URL
 var templateClientDownloadUrl string
 
+// APP_TEMPLATE_CLIENT_EVENTS_URL
+var templateClientEventsUrl string
+
 // APP_TEMPLATE_CLIENT_VERSION_URL
 var templateClientVersionUrl string
 
@@ -45,11 +54,14 @@ var dir string
 // Config fields correspond to config file keys less the prefix
 type Config struct {
 	addr                      string // APP_ADDR
+	dev                       string // APP_DEV
 	exe                       string // APP_EXE
 	maxBytesKb                string // APP_MAX_BYTES_KB
 	maxPayloadMb              string // APP_MAX_PAYLOAD_MB
 	name                      string // APP_NAME
+	paginatorSecret           string // APP_PAGINATOR_SECRET
 	templateClientDownloadUrl string // APP_TEMPLATE_CLIENT_DOWNLOAD_URL
+	templateClientEventsUrl   string // APP_TEMPLATE_CLIENT_EVENTS_URL
 	templateClientVersionUrl  string // APP_TEMPLATE_CLIENT_VERSION_URL
 	version                   string // APP_VERSION
 	awsProfile                string // AWS_PROFILE
@@ -61,6 +73,11 @@ func (c *Config) Addr() string {
 	return c.addr
 }
 
+// Dev is APP_DEV
+func (c *Config) Dev() string {
+	return c.dev
+}
+
 // Exe is APP_EXE
 func (c *Config) Exe() string {
 	return c.exe
@@ -81,11 +98,21 @@ func (c *Config) Name() string {
 	return c.name
 }
 
+// PaginatorSecret is APP_PAGINATOR_SECRET
+func (c *Config) PaginatorSecret() string {
+	return c.paginatorSecret
+}
+
 // TemplateClientDownloadUrl is APP_TEMPLATE_CLIENT_DOWNLOAD_URL
 func (c *Config) TemplateClientDownloadUrl() string {
 	return c.templateClientDownloadUrl
 }
 
+// TemplateClientEventsUrl is APP_TEMPLATE_CLIENT_EVENTS_URL
+func (c *Config) TemplateClientEventsUrl() string {
+	return c.templateClientEventsUrl
+}
+
 // TemplateClientVersionUrl is APP_TEMPLATE_CLIENT_VERSION_URL
 func (c *Config) TemplateClientVersionUrl() string {
 	return c.templateClientVersionUrl
@@ -111,6 +138,11 @@ func (c *Config) SetAddr(v string) {
 	c.addr = v
 }
 
+// SetDev overrides the value of dev
+func (c *Config) SetDev(v string) {
+	c.dev = v
+}
+
 // SetExe overrides the value of exe
 func (c *Config) SetExe(v string) {
 	c.exe = v
@@ -131,11 +163,21 @@ func (c *Config) SetName(v string) {
 	c.name = v
 }
 
+// SetPaginatorSecret overrides the value of paginatorSecret
+func (c *Config) SetPaginatorSecret(v string) {
+	c.paginatorSecret = v
+}
+
 // SetTemplateClientDownloadUrl overrides the value of templateClientDownloadUrl
 func (c *Config) SetTemplateClientDownloadUrl(v string) {
 	c.templateClientDownloadUrl = v
 }
 
+// SetTemplateClientEventsUrl overrides the value of templateClientEventsUrl
+func (c *Config) SetTemplateClientEventsUrl(v string) {
+	c.templateClientEventsUrl = v
+}
+
 // SetTemplateClientVersionUrl overrides the value of templateClientVersionUrl
 func (c *Config) SetTemplateClientVersionUrl(v string) {
 	c.templateClientVersionUrl = v
@@ -175,6 +217,10 @@ func SetVars(conf *Config) {
 		conf.addr = addr
 	}
 
+	if dev != "" {
+		conf.dev = dev
+	}
+
 	if exe != "" {
 		conf.exe = exe
 	}
@@ -191,10 +237,18 @@ func SetVars(conf *Config) {
 		conf.name = name
 	}
 
+	if paginatorSecret != "" {
+		conf.paginatorSecret = paginatorSecret
+	}
+
 	if templateClientDownloadUrl != "" {
 		conf.templateClientDownloadUrl = templateClientDownloadUrl
 	}
 
+	if templateClientEventsUrl != "" {
+		conf.templateClientEventsUrl = templateClientEventsUrl
+	}
+
 	if templateClientVersionUrl != "" {
 		conf.templateClientVersionUrl = templateClientVersionUrl
 	}
@@ -222,6 +276,11 @@ func SetEnv(conf *Config) {
 		conf.addr = v
 	}
 
+	v = os.Getenv("APP_DEV")
+	if v != "" {
+		conf.dev = v
+	}
+
 	v = os.Getenv("APP_EXE")
 	if v != "" {
 		conf.exe = v
@@ -242,11 +301,21 @@ func SetEnv(conf *Config) {
 		conf.name = v
 	}
 
+	v = os.Getenv("APP_PAGINATOR_SECRET")
+	if v != "" {
+		conf.paginatorSecret = v
+	}
+
 	v = os.Getenv("APP_TEMPLATE_CLIENT_DOWNLOAD_URL")
 	if v != "" {
 		conf.templateClientDownloadUrl = v
 	}
 
+	v = os.Getenv("APP_TEMPLATE_CLIENT_EVENTS_URL")
+	if v != "" {
+		conf.templateClientEventsUrl = v
+	}
+
 	v = os.Getenv("APP_TEMPLATE_CLIENT_VERSION_URL")
 	if v != "" {
 		conf.templateClientVersionUrl = v
@@ -275,6 +344,8 @@ func (c *Config) GetMap() map[string]string {
 
 	m["APP_ADDR"] = c.addr
 
+	m["APP_DEV"] = c.dev
+
 	m["APP_EXE"] = c.exe
 
 	m["APP_MAX_BYTES_KB"] = c.maxBytesKb
@@ -283,8 +354,12 @@ func (c *Config) GetMap() map[string]string {
 
 	m["APP_NAME"] = c.name
 
+	m["APP_PAGINATOR_SECRET"] = c.paginatorSecret
+
 	m["APP_TEMPLATE_CLIENT_DOWNLOAD_URL"] = c.templateClientDownloadUrl
 
+	m["APP_TEMPLATE_CLIENT_EVENTS_URL"] = c.templateClientEventsUrl
+
 	m["APP_TEMPLATE_CLIENT_VERSION_URL"] = c.templateClientVersionUrl
 
 	m["APP_VERSION"] = c.version
@@ -0,0 +1,56 @@
+package config_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/stretchr/testify/require"
+)
+
+// TestGenerated fails if the package is not generated from the sample config,
+// run ./make.sh gen_pkg_config after adding keys
+func TestGenerated(t *testing.T) {
+	b, err := ioutil.ReadFile("../../sample.config.dev.json")
+	require.NoError(t, err)
+	sample := map[string]string{}
+	require.NoError(t, json.Unmarshal(b, &sample))
+	keys := []string{"APP_DIR"}
+	for key := range sample {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conf := config.New()
+	generated := []string{}
+	for key := range conf.GetMap() {
+		generated = append(generated, key)
+	}
+	sort.Strings(generated)
+	require.Equal(t, keys, generated)
+
+	// Getter, setter and fn for each key
+	rt := reflect.TypeOf(conf)
+	for _, key := range keys {
+		name := camel(key)
+		for _, method := range []string{name, "Set" + name, "Fn" + name} {
+			_, ok := rt.MethodByName(method)
+			require.True(t, ok, "%s for %s", method, key)
+		}
+	}
+}
+
+// camel converts the key to the method name, e.g. APP_MAX_BYTES_KB is
+// MaxBytesKb, the APP prefix is removed
+func camel(key string) string {
+	key = strings.TrimPrefix(key, "APP_")
+	b := strings.Builder{}
+	for _, part := range strings.Split(strings.ToLower(key), "_") {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
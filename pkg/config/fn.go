@@ -26,6 +26,14 @@ func (c *Config) FnAddr() *Fn {
 	return &fn
 }
 
+// FnDev sets the function input to the value of APP_DEV
+func (c *Config) FnDev() *Fn {
+	fn := Fn{}
+	fn.input = c.dev
+	fn.output = ""
+	return &fn
+}
+
 // FnExe sets the function input to the value of APP_EXE
 func (c *Config) FnExe() *Fn {
 	fn := Fn{}
@@ -58,6 +66,14 @@ func (c *Config) FnName() *Fn {
 	return &fn
 }
 
+// FnPaginatorSecret sets the function input to the value of APP_PAGINATOR_SECRET
+func (c *Config) FnPaginatorSecret() *Fn {
+	fn := Fn{}
+	fn.input = c.paginatorSecret
+	fn.output = ""
+	return &fn
+}
+
 // FnTemplateClientDownloadUrl sets the function input to the value of APP_TEMPLATE_CLIENT_DOWNLOAD_URL
 func (c *Config) FnTemplateClientDownloadUrl() *Fn {
 	fn := Fn{}
@@ -66,6 +82,14 @@ func (c *Config) FnTemplateClientDownloadUrl() *Fn {
 	return &fn
 }
 
+// FnTemplateClientEventsUrl sets the function input to the value of APP_TEMPLATE_CLIENT_EVENTS_URL
+func (c *Config) FnTemplateClientEventsUrl() *Fn {
+	fn := Fn{}
+	fn.input = c.templateClientEventsUrl
+	fn.output = ""
+	return &fn
+}
+
 // FnTemplateClientVersionUrl sets the function input to the value of APP_TEMPLATE_CLIENT_VERSION_URL
 func (c *Config) FnTemplateClientVersionUrl() *Fn {
 	fn := Fn{}
@@ -18,6 +18,17 @@ func (c *Config) ExecTemplateClientDownloadUrl(token string) string {
 	return b.String()
 }
 
+// ExecTemplateClientEventsUrl fills APP_TEMPLATE_CLIENT_EVENTS_URL with the given params
+func (c *Config) ExecTemplateClientEventsUrl(token string) string {
+	t := template.Must(template.New("templateClientEventsUrl").Parse(c.templateClientEventsUrl))
+	b := bytes.Buffer{}
+	_ = t.Execute(&b, map[string]interface{}{
+
+		"Token": token,
+	})
+	return b.String()
+}
+
 // ExecTemplateClientVersionUrl fills APP_TEMPLATE_CLIENT_VERSION_URL with the given params
 func (c *Config) ExecTemplateClientVersionUrl(token string) string {
 	t := template.Must(template.New("templateClientVersionUrl").Parse(c.templateClientVersionUrl))
@@ -0,0 +1,330 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+)
+
+// Struct tags used by Bind to map request values to fields
+const (
+	TagForm  = "form"
+	TagQuery = "query"
+	TagParam = "param"
+)
+
+// bindMaxMemory of multipart forms, files larger than this are written to
+// temp files that are removed by the server after the request
+const bindMaxMemory = 32 << 20
+
+// BindError is returned by Bind if the request body can't be read,
+// Code is the response status, e.g. 400 for malformed JSON
+type BindError struct {
+	Code int
+	err  error
+}
+
+func (e *BindError) Error() string {
+	return e.err.Error()
+}
+
+// Cause is used by errors.Cause
+func (e *BindError) Cause() error {
+	return e.err
+}
+
+// newBindError for errors reading the request body,
+// the code is 413 if the body exceeds the MaxBytes limit
+func newBindError(code int, err error) error {
+	if _, ok := errors.Cause(err).(*http.MaxBytesError); ok {
+		code = http.StatusRequestEntityTooLarge
+	}
+	return &BindError{Code: code, err: errors.WithStack(err)}
+}
+
+// BindStatus returns the response status for an error returned by Bind,
+// 422 for *ValidationError, BindError.Code, or 500 for other errors
+func BindStatus(err error) int {
+	switch v := err.(type) {
+	case *ValidationError:
+		return http.StatusUnprocessableEntity
+	case *BindError:
+		return v.Code
+	}
+	return http.StatusInternalServerError
+}
+
+// Bind fills the struct pointed to by v from the request.
+// The JSON body is decoded first, then fields are set from form values,
+// the query string, and httprouter params using the "form", "query"
+// and "param" struct tags. Later sources override earlier ones.
+// Finally the "validate" tags are checked, see Validate.
+// Returns *ValidationError if any field is invalid,
+// or *BindError if the body is malformed or the content type unsupported.
+// Use BindStatus for the response status, e.g.
+//
+//	h.JSON(handler.BindStatus(err), w, r, err)
+func (h *Handler) Bind(r *http.Request, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("bind target must be a pointer to a struct")
+	}
+
+	// JSON body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "", "application/json":
+		b, err := h.GetBody(r)
+		if err != nil {
+			return newBindError(http.StatusBadRequest, err)
+		}
+		if len(b) > 0 {
+			err = json.Unmarshal(b, v)
+			if err != nil {
+				return bindJSONError(b, err)
+			}
+		}
+
+	case "application/x-www-form-urlencoded":
+		err := r.ParseForm()
+		if err != nil {
+			return newBindError(http.StatusBadRequest, err)
+		}
+
+	case "multipart/form-data":
+		// Values are also set on PostForm, files are ignored.
+		// Use Upload to stream large files to disk
+		err := r.ParseMultipartForm(bindMaxMemory)
+		if err != nil {
+			return newBindError(http.StatusBadRequest, err)
+		}
+
+	default:
+		if r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0 {
+			return newBindError(http.StatusUnsupportedMediaType, errors.Errorf(
+				"unsupported content type %s", mediaType))
+		}
+	}
+
+	verr := &ValidationError{}
+	params := httprouter.ParamsFromContext(r.Context())
+	query := r.URL.Query()
+	bindValues(rv.Elem(), func(field reflect.StructField) (
+		tag string, values []string, ok bool) {
+		// Order of precedence is the reverse of the list below
+		if name := field.Tag.Get(TagParam); name != "" {
+			for _, p := range params {
+				if p.Key == name {
+					return name, []string{p.Value}, true
+				}
+			}
+		}
+		if name := field.Tag.Get(TagQuery); name != "" {
+			if values, ok := query[name]; ok {
+				return name, values, true
+			}
+		}
+		if name := field.Tag.Get(TagForm); name != "" && r.PostForm != nil {
+			if values, ok := r.PostForm[name]; ok {
+				return name, values, true
+			}
+		}
+		return "", nil, false
+	}, verr)
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+
+	return Validate(v)
+}
+
+// bindJSONError maps JSON type errors to a field error,
+// other errors are malformed JSON
+func bindJSONError(b []byte, err error) error {
+	typeErr, ok := err.(*json.UnmarshalTypeError)
+	if ok && typeErr.Field != "" {
+		field := jsonPath(b, typeErr.Offset)
+		if field == "" {
+			field = typeErr.Field
+		}
+		return &ValidationError{Errors: []share.FieldError{{
+			Field:   field,
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type.Kind()),
+		}}}
+	}
+	return newBindError(http.StatusBadRequest, err)
+}
+
+// jsonPathElem is an object key or array index on the path to a value
+type jsonPathElem struct {
+	array bool
+	key   string
+	index int
+}
+
+// jsonPath returns the path of the value that ends at offset in b,
+// e.g. "items[2].name". json.UnmarshalTypeError.Field omits array indexes
+func jsonPath(b []byte, offset int64) string {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	stack := []jsonPathElem{}
+	// expectKey is true if the next string in an object is a key
+	expectKey := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return ""
+		}
+		delim, isDelim := tok.(json.Delim)
+		if isDelim && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			expectKey = len(stack) > 0 && !stack[len(stack)-1].array
+			continue
+		}
+		if len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if !top.array && expectKey {
+				top.key = tok.(string)
+				expectKey = false
+				continue
+			}
+			if top.array {
+				top.index++
+			}
+		}
+		if dec.InputOffset() >= offset {
+			return formatJSONPath(stack)
+		}
+		if isDelim {
+			stack = append(stack, jsonPathElem{array: delim == '[', index: -1})
+			expectKey = delim == '{'
+			continue
+		}
+		expectKey = len(stack) > 0 && !stack[len(stack)-1].array
+	}
+}
+
+// formatJSONPath formats the path like the validate errors
+func formatJSONPath(stack []jsonPathElem) string {
+	path := strings.Builder{}
+	for _, e := range stack {
+		if e.array {
+			path.WriteString(fmt.Sprintf("[%d]", e.index))
+			continue
+		}
+		if path.Len() > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(e.key)
+	}
+	return path.String()
+}
+
+// bindValues walks the exported fields of the struct rv,
+// and sets the values returned by lookup
+func bindValues(rv reflect.Value,
+	lookup func(field reflect.StructField) (string, []string, bool),
+	verr *ValidationError) {
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		fv := rv.Field(i)
+
+		if field.Anonymous && fv.Kind() == reflect.Struct {
+			bindValues(fv, lookup, verr)
+			continue
+		}
+
+		name, values, ok := lookup(field)
+		if !ok {
+			continue
+		}
+		err := setValues(fv, values)
+		if err != nil {
+			verr.Add(name, err.Error())
+		}
+	}
+}
+
+// setValues converts the string values to the type of fv
+func setValues(fv reflect.Value, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Ptr:
+		v := reflect.New(fv.Type().Elem())
+		err := setValues(v.Elem(), values)
+		if err != nil {
+			return err
+		}
+		fv.Set(v)
+		return nil
+
+	case reflect.Slice:
+		s := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, value := range values {
+			err := setValue(s.Index(i), value)
+			if err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+		return nil
+	}
+
+	return setValue(fv, values[0])
+}
+
+func setValue(fv reflect.Value, value string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Errorf("must be a boolean")
+		}
+		fv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, fv.Type().Bits())
+		if err != nil {
+			return errors.Errorf("must be an integer")
+		}
+		fv.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(strings.TrimSpace(value), 10, fv.Type().Bits())
+		if err != nil {
+			return errors.Errorf("must be a positive integer")
+		}
+		fv.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), fv.Type().Bits())
+		if err != nil {
+			return errors.Errorf("must be a number")
+		}
+		fv.SetFloat(f)
+
+	default:
+		return errors.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+type bindItem struct {
+	Name string `json:"name" validate:"required"`
+	Qty  int    `json:"qty" validate:"min=1,max=10"`
+}
+
+type bindRequest struct {
+	ID     string     `param:"id" validate:"required,pattern=^[a-z]+$"`
+	Limit  int        `query:"limit" validate:"max=100"`
+	Tags   []string   `query:"tag"`
+	Color  string     `json:"color" validate:"enum=red|green"`
+	Items  []bindItem `json:"items" validate:"required,max=2"`
+	Parent *bindItem  `json:"parent"`
+}
+
+func TestBind(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	newRequest := func(id, query, body string) *http.Request {
+		req, err := http.NewRequest(
+			"POST", "/items/"+id+"?"+query, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		ctx := context.WithValue(req.Context(), httprouter.ParamsKey,
+			httprouter.Params{{Key: "id", Value: id}})
+		return req.WithContext(ctx)
+	}
+
+	// Valid
+	var v bindRequest
+	req := newRequest("abc", "limit=5&tag=a&tag=b",
+		`{"color": "red", "items": [{"name": "x", "qty": 2}]}`)
+	err = h.Bind(req, &v)
+	require.NoError(t, err)
+	require.Equal(t, "abc", v.ID)
+	require.Equal(t, 5, v.Limit)
+	require.Equal(t, []string{"a", "b"}, v.Tags)
+	require.Equal(t, "red", v.Color)
+	require.Len(t, v.Items, 1)
+
+	// Type conversion
+	v = bindRequest{}
+	req = newRequest("abc", "limit=five", `{"items": [{"name": "x"}]}`)
+	err = h.Bind(req, &v)
+	verr, ok := err.(*handler.ValidationError)
+	require.True(t, ok)
+	require.Equal(t, []share.FieldError{
+		{Field: "limit", Message: "must be an integer"},
+	}, verr.Errors)
+
+	// Every field error is listed with the JSON path
+	v = bindRequest{}
+	req = newRequest("ABC", "limit=500",
+		`{"color": "blue", "items": [{"qty": 11}], "parent": {"qty": 1}}`)
+	err = h.Bind(req, &v)
+	verr, ok = err.(*handler.ValidationError)
+	require.True(t, ok)
+	require.Equal(t, []share.FieldError{
+		{Field: "id", Message: "must match pattern ^[a-z]+$"},
+		{Field: "limit", Message: "must be at most 100"},
+		{Field: "color", Message: "must be one of red, green"},
+		{Field: "items[0].name", Message: "is required"},
+		{Field: "items[0].qty", Message: "must be at most 10"},
+		{Field: "parent.name", Message: "is required"},
+	}, verr.Errors)
+
+	// Validation errors are written with status 422
+	rec := httptest.NewRecorder()
+	h.JSON(http.StatusUnprocessableEntity, rec, req, err)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	resp := share.ValidationErrResponse{}
+	err = json.Unmarshal(rec.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Len(t, resp.Errors, 6)
+	require.Equal(t, "items[0].name", resp.Errors[3].Field)
+
+	// Type errors have the JSON path with array indexes
+	v = bindRequest{}
+	req = newRequest("abc", "",
+		`{"items": [{"name": "x"}, {"name": "y"}, {"name": 3}]}`)
+	err = h.Bind(req, &v)
+	require.Equal(t, http.StatusUnprocessableEntity, handler.BindStatus(err))
+	verr, ok = err.(*handler.ValidationError)
+	require.True(t, ok)
+	require.Equal(t, []share.FieldError{
+		{Field: "items[2].name", Message: "must be of type string"},
+	}, verr.Errors)
+}
+
+func TestBindError(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	for _, tc := range []struct {
+		contentType, body string
+		code              int
+	}{
+		{"application/json", `{"items": [`, http.StatusBadRequest},
+		{"application/json", `[1, 2]`, http.StatusBadRequest},
+		{"multipart/form-data", "name=foo", http.StatusBadRequest},
+		{"text/plain", "hello", http.StatusUnsupportedMediaType},
+	} {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		err = h.Bind(req, &bindRequest{})
+		_, ok := err.(*handler.BindError)
+		require.True(t, ok, tc.body)
+		require.Equal(t, tc.code, handler.BindStatus(err), tc.body)
+	}
+
+	// Body exceeds the MaxBytes limit
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/",
+		strings.NewReader(`{"color": "red"}`))
+	req.Body = http.MaxBytesReader(rec, req.Body, 4)
+	err = h.Bind(req, &bindRequest{})
+	require.Equal(t, http.StatusRequestEntityTooLarge, handler.BindStatus(err))
+}
+
+type bindForm struct {
+	Name string `form:"name" validate:"required"`
+	Qty  int    `form:"qty" validate:"min=1"`
+}
+
+func TestBindForm(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	// Multipart
+	var body strings.Builder
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("name", "foo"))
+	require.NoError(t, mw.WriteField("qty", "3"))
+	require.NoError(t, mw.Close())
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body.String()))
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	v := bindForm{}
+	require.NoError(t, h.Bind(req, &v))
+	require.Equal(t, bindForm{Name: "foo", Qty: 3}, v)
+
+	// Url encoded, zero values that are present are validated
+	req = httptest.NewRequest("POST", "/", strings.NewReader("name=foo&qty=0"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	v = bindForm{}
+	err = h.Bind(req, &v)
+	verr, ok := err.(*handler.ValidationError)
+	require.True(t, ok)
+	require.Equal(t, []share.FieldError{
+		{Field: "qty", Message: "must be at least 1"},
+	}, verr.Errors)
+}
+
+func TestValidateZero(t *testing.T) {
+	// Zero numbers are checked
+	err := handler.Validate(&struct {
+		Qty int `validate:"min=1"`
+	}{})
+	verr, ok := err.(*handler.ValidationError)
+	require.True(t, ok)
+	require.Equal(t, []share.FieldError{
+		{Field: "Qty", Message: "must be at least 1"},
+	}, verr.Errors)
+
+	// Absent values are only checked by the required rule
+	require.NoError(t, handler.Validate(&struct {
+		Qty   *int     `validate:"min=1"`
+		Color string   `validate:"enum=red|green"`
+		Tags  []string `validate:"min=1"`
+	}{}))
+}
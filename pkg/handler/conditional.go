@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ETag types for ResponseOptions
+const (
+	// ETagStrong tags are byte-for-byte identical representations
+	ETagStrong = "strong"
+	// ETagWeak tags are semantically equivalent representations
+	ETagWeak = "weak"
+)
+
+type ResponseOptions struct {
+	// ETag is ETagStrong or ETagWeak to compute an entity tag from the
+	// response body, and answer If-None-Match with 304 Not Modified
+	ETag string
+	// LastModified is set as the Last-Modified header if not zero,
+	// and compared with If-Modified-Since
+	LastModified time.Time
+}
+
+// ETag computes an entity tag for b,
+// use it to check If-Match preconditions, see CheckPreconditions
+func ETag(b []byte, weak bool) string {
+	sum := sha256.Sum256(b)
+	tag := "\"" + hex.EncodeToString(sum[:16]) + "\""
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// notModified sets the validator headers for a 200 response,
+// and returns true if the client has a fresh copy
+func notModified(w http.ResponseWriter, r *http.Request, b []byte,
+	o *ResponseOptions) bool {
+
+	etag := ""
+	switch o.ETag {
+	case ETagStrong:
+		etag = ETag(b, false)
+	case ETagWeak:
+		etag = ETag(b, true)
+	}
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !o.LastModified.IsZero() {
+		w.Header().Set("Last-Modified",
+			o.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since,
+	// https://tools.ietf.org/html/rfc7232#section-6
+	inm := r.Header.Get("If-None-Match")
+	if inm != "" {
+		return etag != "" && matchETag(inm, etag, true)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims != "" && !o.LastModified.IsZero() {
+		t, err := http.ParseTime(ims)
+		if err == nil {
+			// Header has second precision
+			return !o.LastModified.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
+
+// CheckPreconditions evaluates If-Match and If-Unmodified-Since for requests
+// that change a resource, e.g. PUT, to support optimistic concurrency.
+// Pass the ETag and modification time of the current representation,
+// an empty etag means the resource does not exist.
+// Returns false after responding with 412 Precondition Failed
+func (h *Handler) CheckPreconditions(w http.ResponseWriter, r *http.Request,
+	etag string, lastModified time.Time) bool {
+
+	im := r.Header.Get("If-Match")
+	if im != "" {
+		// Strong comparison, weak tags never match
+		if etag == "" || !matchETag(im, etag, false) {
+			h.JSON(http.StatusPreconditionFailed, w, r,
+				errors.Errorf("If-Match precondition failed"))
+			return false
+		}
+		return true
+	}
+
+	ius := r.Header.Get("If-Unmodified-Since")
+	if ius != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ius)
+		if err == nil && lastModified.Truncate(time.Second).After(t) {
+			h.JSON(http.StatusPreconditionFailed, w, r,
+				errors.Errorf("If-Unmodified-Since precondition failed"))
+			return false
+		}
+	}
+	return true
+}
+
+// matchETag returns true if the header lists etag, or is "*"
+func matchETag(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") ==
+				strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
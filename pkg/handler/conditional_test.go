@@ -0,0 +1,79 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConditional(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	o := &handler.ResponseOptions{
+		ETag:         handler.ETagStrong,
+		LastModified: modTime,
+	}
+	resp := share.Response{Message: "foo"}
+
+	// Validators are set
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	h.JSONWithOptions(http.StatusOK, rec, req, resp, o)
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	require.Equal(t, "Thu, 02 Jan 2020 03:04:05 GMT",
+		rec.Header().Get("Last-Modified"))
+
+	// If-None-Match, weak comparison
+	for _, inm := range []string{etag, "W/" + etag, `"abc", ` + etag, "*"} {
+		req.Header.Set("If-None-Match", inm)
+		rec = httptest.NewRecorder()
+		h.JSONWithOptions(http.StatusOK, rec, req, resp, o)
+		require.Equal(t, http.StatusNotModified, rec.Code, inm)
+		require.Empty(t, rec.Body.String())
+	}
+	req.Header.Set("If-None-Match", `"abc"`)
+	rec = httptest.NewRecorder()
+	h.JSONWithOptions(http.StatusOK, rec, req, resp, o)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// If-Modified-Since
+	req.Header.Del("If-None-Match")
+	req.Header.Set("If-Modified-Since", modTime.Format(http.TimeFormat))
+	rec = httptest.NewRecorder()
+	h.WriteWithOptions(http.StatusOK, "", rec, req, []byte("foo"), o)
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	req.Header.Set("If-Modified-Since",
+		modTime.Add(-time.Second).Format(http.TimeFormat))
+	rec = httptest.NewRecorder()
+	h.WriteWithOptions(http.StatusOK, "", rec, req, []byte("foo"), o)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// If-Match
+	current := handler.ETag([]byte("foo"), false)
+	req, err = http.NewRequest("PUT", "/", nil)
+	require.NoError(t, err)
+	req.Header.Set("If-Match", current)
+	rec = httptest.NewRecorder()
+	require.True(t, h.CheckPreconditions(rec, req, current, time.Time{}))
+	req.Header.Set("If-Match", "W/"+current)
+	rec = httptest.NewRecorder()
+	require.False(t, h.CheckPreconditions(rec, req, current, time.Time{}))
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	req.Header.Set("If-Match", "*")
+	rec = httptest.NewRecorder()
+	require.False(t, h.CheckPreconditions(rec, req, "", time.Time{}))
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+}
@@ -0,0 +1,354 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/openapi"
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/mozey/httprouter-util/pkg/share"
+)
+
+// Schema components for error responses, see share.ErrResponse
+const (
+	SchemaErrResponse           = "ErrResponse"
+	SchemaValidationErrResponse = "ValidationErrResponse"
+)
+
+type DocsOptions struct {
+	Title       string
+	Version     string
+	Description string
+	// SecuritySchemes are keyed by route policy, e.g. share.PolicyToken.
+	// Routes with share.PolicyPublic do not require security
+	SecuritySchemes map[string]*openapi.SecurityScheme
+}
+
+// OpenAPI generates the document for the routes in the Registry
+func (h *Handler) OpenAPI(o *DocsOptions) *openapi.Document {
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       o.Title,
+			Version:     o.Version,
+			Description: o.Description,
+		},
+		Paths: make(map[string]openapi.PathItem),
+		Components: &openapi.Components{
+			Schemas: map[string]*schema.Schema{
+				SchemaErrResponse: reflectSchema(
+					reflect.TypeOf(share.ErrResponse{})),
+				SchemaValidationErrResponse: reflectSchema(
+					reflect.TypeOf(share.ValidationErrResponse{})),
+			},
+			SecuritySchemes: o.SecuritySchemes,
+		},
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "dev"
+	}
+
+	routes, options := h.Registry.sorted()
+	for i, route := range routes {
+		path := openapi.Path(route.Pattern)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(openapi.PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation(route, options[i], o)
+	}
+	return doc
+}
+
+// Docs serves the OpenAPI document as JSON.
+// The document is generated on the first request,
+// after all routes are registered
+func (h *Handler) Docs(o *DocsOptions) http.HandlerFunc {
+	var once sync.Once
+	var doc *openapi.Document
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			doc = h.OpenAPI(o)
+		})
+		h.JSON(http.StatusOK, w, r, doc)
+	}
+}
+
+func jsonContent(s *schema.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{
+		"application/json": {Schema: s},
+	}
+}
+
+// operation documents the route
+func operation(route share.Route, ro *RouteOptions, o *DocsOptions) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: route.Name,
+		Summary:     route.Description,
+		Tags:        route.Tags,
+		Responses:   make(map[string]*openapi.Response),
+	}
+
+	// Security
+	security := []openapi.SecurityRequirement{}
+	if _, ok := o.SecuritySchemes[route.Policy]; ok &&
+		route.Policy != share.PolicyPublic {
+		security = append(security,
+			openapi.SecurityRequirement{route.Policy: []string{}})
+	}
+	op.Security = &security
+
+	// Parameters
+	var requestType reflect.Type
+	if ro.Request != nil {
+		requestType = reflect.TypeOf(ro.Request)
+		for requestType.Kind() == reflect.Ptr {
+			requestType = requestType.Elem()
+		}
+	}
+	if requestType != nil && requestType.Kind() == reflect.Struct {
+		op.Parameters = reflectParams(requestType, nil)
+	}
+	for _, segment := range strings.Split(route.Pattern, "/") {
+		if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+			continue
+		}
+		name := segment[1:]
+		found := false
+		for _, p := range op.Parameters {
+			found = found || (p.In == openapi.InPath && p.Name == name)
+		}
+		if !found {
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name:     name,
+				In:       openapi.InPath,
+				Required: true,
+				Schema:   &schema.Schema{Type: schema.Types{"string"}},
+			})
+		}
+	}
+
+	// Request body
+	requestSchema := ro.RequestSchema
+	switch route.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		if requestSchema == nil && requestType != nil {
+			requestSchema = reflectSchema(requestType)
+		}
+	default:
+		requestSchema = nil
+	}
+	if requestSchema != nil {
+		op.RequestBody = &openapi.RequestBody{
+			Content: jsonContent(requestSchema),
+		}
+	}
+
+	// Responses
+	ok := &openapi.Response{Description: http.StatusText(http.StatusOK)}
+	if ro.ResponseSchema != nil {
+		ok.Content = jsonContent(ro.ResponseSchema)
+	} else if ro.Response != nil {
+		ok.Content = jsonContent(reflectSchema(reflect.TypeOf(ro.Response)))
+	}
+	op.Responses[strconv.Itoa(http.StatusOK)] = ok
+	if len(security) > 0 {
+		op.Responses[strconv.Itoa(http.StatusBadRequest)] = &openapi.Response{
+			Description: "Invalid token",
+			Content:     jsonContent(openapi.Ref(SchemaErrResponse)),
+		}
+	}
+	if ro.Request != nil || ro.RequestSchema != nil {
+		op.Responses[strconv.Itoa(http.StatusUnprocessableEntity)] =
+			&openapi.Response{
+				Description: "Validation failed",
+				Content: jsonContent(
+					openapi.Ref(SchemaValidationErrResponse)),
+			}
+	}
+	op.Responses["default"] = &openapi.Response{
+		Description: "Error",
+		Content:     jsonContent(openapi.Ref(SchemaErrResponse)),
+	}
+	return op
+}
+
+// reflectParams lists the fields of t with param and query tags
+func reflectParams(t reflect.Type, params []*openapi.Parameter) []*openapi.Parameter {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			params = reflectParams(field.Type, params)
+			continue
+		}
+		for _, in := range []struct{ tag, in string }{
+			{TagParam, openapi.InPath},
+			{TagQuery, openapi.InQuery},
+		} {
+			name := field.Tag.Get(in.tag)
+			if name == "" {
+				continue
+			}
+			s := reflectType(field.Type, map[reflect.Type]bool{})
+			rules := parseRules(field.Tag.Get(TagValidate))
+			applyRules(s, rules)
+			_, required := rules["required"]
+			params = append(params, &openapi.Parameter{
+				Name:     name,
+				In:       in.in,
+				Required: required || in.in == openapi.InPath,
+				Schema:   s,
+			})
+		}
+	}
+	return params
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// reflectSchema generates a JSON Schema for the type,
+// property names and constraints are read from the json and validate tags
+func reflectSchema(t reflect.Type) *schema.Schema {
+	return reflectType(t, map[reflect.Type]bool{})
+}
+
+func reflectType(t reflect.Type, seen map[reflect.Type]bool) *schema.Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &schema.Schema{Type: schema.Types{"string"}, Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &schema.Schema{Type: schema.Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schema.Schema{Type: schema.Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &schema.Schema{Type: schema.Types{"number"}}
+	case reflect.String:
+		return &schema.Schema{Type: schema.Types{"string"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Encoded as base64
+			return &schema.Schema{Type: schema.Types{"string"}}
+		}
+		return &schema.Schema{
+			Type:  schema.Types{"array"},
+			Items: reflectType(t.Elem(), seen),
+		}
+	case reflect.Map:
+		return &schema.Schema{
+			Type:                 schema.Types{"object"},
+			AdditionalProperties: reflectType(t.Elem(), seen),
+		}
+	case reflect.Struct:
+		if seen[t] {
+			// Recursive type
+			return schema.Bool(true)
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		s := &schema.Schema{
+			Type:       schema.Types{"object"},
+			Properties: make(map[string]*schema.Schema),
+		}
+		reflectFields(t, s, seen)
+		return s
+	}
+	// Any value, e.g. interface{}
+	return schema.Bool(true)
+}
+
+// reflectFields adds the fields of t to the properties of s
+func reflectFields(t reflect.Type, s *schema.Schema, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		jsonTag := field.Tag.Get("json")
+		name := strings.Split(jsonTag, ",")[0]
+		if name == "-" {
+			continue
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			reflectFields(ft, s, seen)
+			continue
+		}
+		if jsonTag == "" && (field.Tag.Get(TagParam) != "" ||
+			field.Tag.Get(TagQuery) != "" || field.Tag.Get(TagForm) != "") {
+			// Not in the body
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		p := reflectType(field.Type, seen)
+		rules := parseRules(field.Tag.Get(TagValidate))
+		applyRules(p, rules)
+		if _, ok := rules["required"]; ok {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = p
+	}
+}
+
+// applyRules maps validate rules to schema keywords, see Validate
+func applyRules(s *schema.Schema, rules map[string]string) {
+	typ := ""
+	if len(s.Type) > 0 {
+		typ = s.Type[0]
+	}
+	for rule, arg := range rules {
+		switch rule {
+		case "min", "max":
+			f, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			n := int(f)
+			switch {
+			case typ == "string" && rule == "min":
+				s.MinLength = &n
+			case typ == "string":
+				s.MaxLength = &n
+			case typ == "array" && rule == "min":
+				s.MinItems = &n
+			case typ == "array":
+				s.MaxItems = &n
+			case (typ == "integer" || typ == "number") && rule == "min":
+				s.Minimum = &f
+			case typ == "integer" || typ == "number":
+				s.Maximum = &f
+			}
+		case "enum":
+			for _, v := range strings.Split(arg, "|") {
+				if typ == "integer" || typ == "number" {
+					f, err := strconv.ParseFloat(v, 64)
+					if err == nil {
+						s.Enum = append(s.Enum, f)
+					}
+					continue
+				}
+				s.Enum = append(s.Enum, v)
+			}
+		case "pattern":
+			s.Pattern = arg
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/openapi"
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+type docsItem struct {
+	ID    string   `json:"id" param:"id" validate:"required"`
+	Name  string   `json:"name" validate:"required,max=64"`
+	Color string   `json:"color,omitempty" validate:"enum=red|green"`
+	Tags  []string `json:"tags" validate:"max=3"`
+	Count int      `json:"count" validate:"min=1"`
+	Dry   bool     `query:"dry"`
+	Skip  string   `json:"-"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	h.HandlerFunc("PUT", "/items/:id", ok, &handler.RouteOptions{
+		Name: "items.put", Description: "Update an item",
+		Tags: []string{"items"}, Request: docsItem{}, Response: &docsItem{},
+	})
+	h.HandlerFunc("GET", "/files/*path", ok, &handler.RouteOptions{
+		Policy: share.PolicyPublic,
+	})
+	h.HandlerFunc("GET", "/openapi.json", h.Docs(&handler.DocsOptions{
+		Title: "test",
+		SecuritySchemes: map[string]*openapi.SecurityScheme{
+			share.PolicyToken: {Type: "apiKey", In: "query", Name: "token"},
+		},
+	}), &handler.RouteOptions{Policy: share.PolicyPublic})
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	h.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	doc, err := openapi.Parse(rec.Body.Bytes())
+	require.NoError(t, err)
+	require.Equal(t, openapi.Version, doc.OpenAPI)
+	require.Equal(t, "test", doc.Info.Title)
+
+	// Path params, query params and body
+	op := doc.Paths["/items/{id}"]["put"]
+	require.NotNil(t, op)
+	require.Equal(t, "items.put", op.OperationID)
+	require.Len(t, op.Parameters, 2)
+	require.Equal(t, "id", op.Parameters[0].Name)
+	require.Equal(t, openapi.InPath, op.Parameters[0].In)
+	require.True(t, op.Parameters[0].Required)
+	require.Equal(t, "dry", op.Parameters[1].Name)
+	require.Equal(t, schema.Types{"boolean"}, op.Parameters[1].Schema.Type)
+
+	body := op.RequestBody.Content["application/json"].Schema
+	b, err := json.Marshal(body)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"type": "object",
+		"required": ["id", "name"],
+		"properties": {
+			"id": {"type": "string"},
+			"name": {"type": "string", "maxLength": 64},
+			"color": {"type": "string", "enum": ["red", "green"]},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 3},
+			"count": {"type": "integer", "minimum": 1}
+		}
+	}`, string(b))
+	require.NotNil(t, op.Responses["200"].Content["application/json"].Schema)
+
+	// Security and errors
+	require.Equal(t, []openapi.SecurityRequirement{{"token": {}}}, *op.Security)
+	require.Equal(t, openapi.RefPrefix+handler.SchemaErrResponse,
+		op.Responses["400"].Content["application/json"].Schema.Ref)
+	require.Equal(t, openapi.RefPrefix+handler.SchemaValidationErrResponse,
+		op.Responses["422"].Content["application/json"].Schema.Ref)
+	require.Contains(t, doc.Components.Schemas, handler.SchemaErrResponse)
+	require.Contains(t, doc.Components.SecuritySchemes, share.PolicyToken)
+
+	op = doc.Paths["/files/{path}"]["get"]
+	require.NotNil(t, op)
+	require.Empty(t, *op.Security)
+	require.Equal(t, "path", op.Parameters[0].Name)
+	require.Nil(t, op.Responses["400"])
+}
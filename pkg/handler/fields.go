@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Query params used to control JSON output
+const (
+	QueryPretty = "pretty"
+	QueryFields = "fields"
+)
+
+// indent for pretty JSON responses
+const indent = "    "
+
+// pretty returns false if the client asked for compact JSON,
+// with the pretty query param, or a pretty parameter on the Accept header,
+// e.g. "Accept: application/json; pretty=false"
+func pretty(r *http.Request, query url.Values) bool {
+	if s := query.Get(QueryPretty); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err == nil {
+			return b
+		}
+	}
+	accept := r.Header.Get("Accept")
+	if !strings.Contains(accept, QueryPretty) {
+		return true
+	}
+	for _, accept := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(accept)
+		if err != nil {
+			continue
+		}
+		if mediaType != "application/json" && mediaType != "*/*" {
+			continue
+		}
+		b, err := strconv.ParseBool(params[QueryPretty])
+		if err == nil {
+			return b
+		}
+	}
+	return true
+}
+
+// fieldSet selects fields from a JSON object,
+// a nil value selects the whole field
+type fieldSet map[string]fieldSet
+
+// parseFields parses a comma separated list of field paths,
+// e.g. "a,b.c" selects the a field, and the c field of the b object
+func parseFields(s string) fieldSet {
+	fields := fieldSet{}
+	for _, path := range strings.Split(s, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		current := fields
+		keys := strings.Split(path, ".")
+		for i, key := range keys {
+			sub, ok := current[key]
+			if ok && sub == nil {
+				// Whole field already selected
+				break
+			}
+			if i == len(keys)-1 {
+				current[key] = nil
+				break
+			}
+			if !ok {
+				sub = fieldSet{}
+				current[key] = sub
+			}
+			current = sub
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// filterFields writes the fields selected from the JSON document b to buf,
+// arrays are filtered element by element. Field order is preserved
+func filterFields(buf *bytes.Buffer, b []byte, fields fieldSet) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return filterValue(dec, buf, fields)
+}
+
+func filterValue(dec *json.Decoder, buf *bytes.Buffer, fields fieldSet) error {
+	if fields == nil {
+		raw := json.RawMessage{}
+		err := dec.Decode(&raw)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		buf.Write(raw)
+		return nil
+	}
+
+	t, err := dec.Token()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	switch t {
+	case json.Delim('{'):
+		buf.WriteByte('{')
+		first := true
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			key, _ := t.(string)
+			sub, ok := fields[key]
+			if !ok {
+				// Skip value
+				err = dec.Decode(&json.RawMessage{})
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				continue
+			}
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			b, _ := json.Marshal(key)
+			buf.Write(b)
+			buf.WriteByte(':')
+			err = filterValue(dec, buf, sub)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		buf.WriteByte('}')
+
+	case json.Delim('['):
+		buf.WriteByte('[')
+		first := true
+		for dec.More() {
+			if !first {
+				buf.WriteByte(',')
+			}
+			first = false
+			err = filterValue(dec, buf, fields)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		buf.WriteByte(']')
+
+	default:
+		// Fields can not be selected from scalars, keep the value
+		b, err := json.Marshal(t)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		buf.Write(b)
+	}
+	return nil
+}
+
+// encode v, only the selected fields are included if fields is not nil
+func (buf *jsonBuffer) encode(v interface{}, pretty bool,
+	fields fieldSet) error {
+
+	if fields == nil {
+		// Single pass
+		if pretty {
+			buf.enc.SetIndent("", indent)
+		} else {
+			buf.enc.SetIndent("", "")
+		}
+		err := buf.enc.Encode(v)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// Trim newline appended by Encode
+		buf.Truncate(buf.Len() - 1)
+		return nil
+	}
+
+	tmp := getBuffer()
+	defer putBuffer(tmp)
+	err := tmp.encode(v, false, nil)
+	if err != nil {
+		return err
+	}
+	if !pretty {
+		return filterFields(&buf.Buffer, tmp.Bytes(), fields)
+	}
+	filtered := getBuffer()
+	defer putBuffer(filtered)
+	err = filterFields(&filtered.Buffer, tmp.Bytes(), fields)
+	if err != nil {
+		return err
+	}
+	return errors.WithStack(json.Indent(&buf.Buffer, filtered.Bytes(), "", indent))
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps a route handler,
+// use a closure to pass options to middleware with an options struct
+type Middleware func(next http.Handler) http.Handler
+
+// Group of routes with a common path prefix and middleware.
+// Group middleware runs after the global middleware, see HTTPHandler,
+// and only for routes registered on the group
+type Group struct {
+	h          *Handler
+	prefix     string
+	middleware []Middleware
+}
+
+// Group creates a route group, middleware is applied in the order listed
+func (h *Handler) Group(prefix string, middleware ...Middleware) *Group {
+	return &Group{
+		h:          h,
+		prefix:     strings.TrimSuffix(prefix, "/"),
+		middleware: middleware,
+	}
+}
+
+// Group creates a nested group, the prefix is appended to the parent prefix.
+// Parent middleware runs first
+func (g *Group) Group(prefix string, middleware ...Middleware) *Group {
+	mw := make([]Middleware, 0, len(g.middleware)+len(middleware))
+	mw = append(mw, g.middleware...)
+	mw = append(mw, middleware...)
+	return &Group{
+		h:          g.h,
+		prefix:     g.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: mw,
+	}
+}
+
+// Path returns the full path for a route in the group
+func (g *Group) Path(path string) string {
+	path = g.prefix + path
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// Handle registers a route handler on the router,
+// and adds it to the Registry
+func (g *Group) Handle(method, path string, handler http.Handler, o *RouteOptions) {
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		handler = g.middleware[i](handler)
+	}
+	// Router panics if the route conflicts with an existing route
+	g.h.Router.Handler(method, g.Path(path), handler)
+	g.h.Registry.Add(method, g.Path(path), o)
+}
+
+// HandlerFunc registers a route handler func, see Handle
+func (g *Group) HandlerFunc(method, path string, fn http.HandlerFunc, o *RouteOptions) {
+	g.Handle(method, path, fn, o)
+}
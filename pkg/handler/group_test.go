@@ -0,0 +1,60 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/stretchr/testify/require"
+)
+
+// trace appends the name to the X-Trace response header
+func trace(name string) handler.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestGroup(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	name := func(w http.ResponseWriter, r *http.Request) {
+		p := httprouter.ParamsFromContext(r.Context())
+		_, _ = w.Write([]byte(p.ByName("name")))
+	}
+	h.Router.HandlerFunc("GET", "/hello/:name", name)
+	api := h.Group("/api/v1/", trace("api"))
+	api.HandlerFunc("GET", "", name, nil)
+	api.HandlerFunc("GET", "/hello/:name", name, nil)
+	admin := api.Group("/admin", trace("admin"), trace("audit"))
+	admin.HandlerFunc("POST", "/hello/:name", name, nil)
+
+	for _, tc := range []struct {
+		method, path, trace, body string
+	}{
+		{"GET", "/hello/foo", "", "foo"},
+		{"GET", "/api/v1", "api", ""},
+		{"GET", "/api/v1/hello/foo", "api", "foo"},
+		{"POST", "/api/v1/admin/hello/bar", "api,admin,audit", "bar"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		rec := httptest.NewRecorder()
+		h.Router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, tc.path)
+		require.Equal(t, tc.trace,
+			strings.Join(rec.Header().Values("X-Trace"), ","), tc.path)
+		require.Equal(t, tc.body, rec.Body.String(), tc.path)
+	}
+
+	require.Equal(t, "/api/v1/admin/users", admin.Path("/users"))
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/mozey/httprouter-util/pkg/config"
@@ -14,12 +15,24 @@ type Handler struct {
 	Router      *httprouter.Router
 	HTTPHandler http.Handler
 	FlushLogs   func()
+	// Renderer for HTML pages, see HTML
+	Renderer *Renderer
+	// Registry of routes registered with Handle
+	Registry *Registry
+
+	// done is closed when the server starts shutting down
+	done         chan struct{}
+	shutdownOnce sync.Once
+	// websockets tracks open connections, see Wait
+	websockets sync.WaitGroup
 }
 
 func NewHandler(conf *config.Config) (h *Handler) {
 	h = &Handler{}
 	h.Config = conf
 	h.Router = httprouter.New()
+	h.Registry = NewRegistry()
+	h.done = make(chan struct{})
 
 	flushLogs, err := SetupLogger(conf)
 	if err != nil {
@@ -43,6 +56,30 @@ func SetupLogger(conf *config.Config) (flushLogs func(), err error) {
 	return func() {}, nil
 }
 
+// Dev returns true if the app is running in dev mode, see APP_DEV.
+// Returns false if the handler has no config
+func (h *Handler) Dev() bool {
+	if h.Config == nil {
+		return false
+	}
+	dev, err := h.Config.FnDev().Bool()
+	return err == nil && dev
+}
+
+// Shutdown signals long-lived connections, e.g. event streams, to close.
+// Register it with http.Server.RegisterOnShutdown,
+// Server.Shutdown does not close nor wait for these connections
+func (h *Handler) Shutdown() {
+	h.shutdownOnce.Do(func() {
+		close(h.done)
+	})
+}
+
+// Done is closed when Shutdown is called
+func (h *Handler) Done() <-chan struct{} {
+	return h.done
+}
+
 // Cleanup function must be called before the application exits
 func (h *Handler) Cleanup() {
 	h.FlushLogs()
@@ -0,0 +1,24 @@
+package handler_test
+
+import (
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDev(t *testing.T) {
+	// Handler without config
+	h := handler.NewHandler(nil)
+	defer h.Cleanup()
+	require.False(t, h.Dev())
+
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h = handler.NewHandler(conf)
+	defer h.Cleanup()
+	dev, err := conf.FnDev().Bool()
+	require.NoError(t, err)
+	require.Equal(t, dev, h.Dev())
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// HostOptions for a host registered on the HostRouter
+type HostOptions struct {
+	// NotFound is set on the host router, unless the router has one
+	NotFound http.Handler
+	// PanicHandler is set on the host router, unless the router has one
+	PanicHandler func(http.ResponseWriter, *http.Request, interface{})
+}
+
+// HostRouter dispatches requests to a handler by the request host,
+// each handler has its own router, route registry, and middleware.
+// Hosts are exact, e.g. "api.example.com", or a wildcard that matches
+// any subdomain, e.g. "*.example.com". Exact hosts are matched first,
+// then the wildcard with the longest suffix. Ports are ignored.
+// Requests for other hosts are handled by the default handler.
+// Shutdown and Cleanup must be called on each handler
+type HostRouter struct {
+	exact     map[string]*Handler
+	wildcards []hostWildcard
+	fallback  *Handler
+}
+
+type hostWildcard struct {
+	// suffix includes the leading dot, e.g. ".example.com"
+	suffix string
+	h      *Handler
+}
+
+// NewHostRouter creates a host router with the default handler
+func NewHostRouter(fallback *Handler, o *HostOptions) *HostRouter {
+	setupHostRouter(fallback.Router, o)
+	return &HostRouter{
+		exact:    make(map[string]*Handler),
+		fallback: fallback,
+	}
+}
+
+// Handle requests for host with h. Panics if the host is already registered,
+// like httprouter does for conflicting routes
+func (hr *HostRouter) Handle(host string, h *Handler, o *HostOptions) {
+	host = normalizeHost(host)
+	setupHostRouter(h.Router, o)
+
+	if strings.HasPrefix(host, "*.") {
+		suffix := host[1:]
+		for _, w := range hr.wildcards {
+			if w.suffix == suffix {
+				panic("host already registered: " + host)
+			}
+		}
+		hr.wildcards = append(hr.wildcards, hostWildcard{suffix: suffix, h: h})
+		sort.SliceStable(hr.wildcards, func(i, j int) bool {
+			return len(hr.wildcards[i].suffix) > len(hr.wildcards[j].suffix)
+		})
+		return
+	}
+
+	if _, ok := hr.exact[host]; ok {
+		panic("host already registered: " + host)
+	}
+	hr.exact[host] = h
+}
+
+// Lookup returns the handler for the host
+func (hr *HostRouter) Lookup(host string) *Handler {
+	host = normalizeHost(host)
+	if h, ok := hr.exact[host]; ok {
+		return h
+	}
+	for _, w := range hr.wildcards {
+		if strings.HasSuffix(host, w.suffix) {
+			return w.h
+		}
+	}
+	return hr.fallback
+}
+
+func (hr *HostRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := hr.Lookup(r.Host)
+	if h.HTTPHandler != nil {
+		// Middleware is set up after routes are registered
+		h.HTTPHandler.ServeHTTP(w, r)
+		return
+	}
+	h.Router.ServeHTTP(w, r)
+}
+
+func setupHostRouter(router *httprouter.Router, o *HostOptions) {
+	if o == nil {
+		return
+	}
+	if router.NotFound == nil {
+		router.NotFound = o.NotFound
+	}
+	if router.PanicHandler == nil {
+		router.PanicHandler = o.PanicHandler
+	}
+}
+
+// normalizeHost removes the port and trailing dot, hosts are case insensitive
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,92 @@
+package handler_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostRouter(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+
+	// Each host has its own routes and middleware
+	newHost := func(name string) *handler.Handler {
+		h := handler.NewHandler(conf)
+		h.HandlerFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(name))
+		}, nil)
+		h.HandlerFunc("GET", "/panic", func(w http.ResponseWriter, r *http.Request) {
+			panic(name)
+		}, nil)
+		h.HTTPHandler = trace(name)(h.Router)
+		return h
+	}
+	api := newHost("api")
+	defer api.Cleanup()
+	admin := newHost("admin")
+	defer admin.Cleanup()
+	tenant := newHost("tenant")
+	defer tenant.Cleanup()
+	fallback := newHost("default")
+	defer fallback.Cleanup()
+
+	o := &handler.HostOptions{
+		NotFound: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}),
+		PanicHandler: func(w http.ResponseWriter, r *http.Request, v interface{}) {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(fmt.Sprint(v)))
+		},
+	}
+	hr := handler.NewHostRouter(fallback, o)
+	hr.Handle("api.example.com", api, o)
+	hr.Handle("Admin.Example.com", admin, o)
+	hr.Handle("*.example.com", tenant, o)
+	hr.Handle("*.eu.example.com", api, o)
+
+	for _, tc := range []struct {
+		host, want string
+	}{
+		{"api.example.com", "api"},
+		{"api.example.com:8118", "api"},
+		{"ADMIN.example.com.", "admin"},
+		{"acme.example.com", "tenant"},
+		{"acme.eu.example.com", "api"},
+		{"example.com", "default"},
+		{"localhost:8118", "default"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		hr.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, tc.host)
+		require.Equal(t, tc.want, rec.Body.String(), tc.host)
+		require.Equal(t, tc.want, rec.Header().Get("X-Trace"), tc.host)
+	}
+
+	// Per host NotFound and PanicHandler
+	req := httptest.NewRequest("GET", "/does/not/exist", nil)
+	req.Host = "admin.example.com"
+	rec := httptest.NewRecorder()
+	hr.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Equal(t, "admin", rec.Header().Get("X-Trace"))
+
+	req = httptest.NewRequest("GET", "/panic", nil)
+	req.Host = "acme.example.com"
+	rec = httptest.NewRecorder()
+	hr.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, "tenant", rec.Body.String())
+
+	// Duplicate hosts
+	require.Panics(t, func() { hr.Handle("api.example.com:80", admin, nil) })
+	require.Panics(t, func() { hr.Handle("*.example.com", admin, nil) })
+}
@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mozey/httprouter-util/pkg/openapi"
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+)
+
+// HeaderPrefer selects the status code of a mock response,
+// e.g. "Prefer: code=404"
+const HeaderPrefer = "Prefer"
+
+// Mock registers a route for each operation in the document.
+// Requests are validated against the parameters and request body,
+// and invalid requests are rejected with a 422 response.
+// The response is the example in the document,
+// or data generated from the response schema, see schema.Example.
+// Operations with security requirements get the share.PolicyToken policy.
+// The document is modified, see openapi.LocalRefs
+func (h *Handler) Mock(doc *openapi.Document) error {
+	doc.LocalRefs()
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		item := doc.Paths[path]
+		methods := make([]string, 0, len(item))
+		for method := range item {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			err := h.mockRoute(doc, strings.ToUpper(method), path, item[method])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mockRoute registers the operation
+func (h *Handler) mockRoute(doc *openapi.Document,
+	method, path string, op *openapi.Operation) (err error) {
+
+	defer func() {
+		// Router panics if the route conflicts with an existing route
+		if rec := recover(); rec != nil {
+			err = errors.Errorf("route %s %s: %v", method, path, rec)
+		}
+	}()
+
+	security := doc.Security
+	if op.Security != nil {
+		security = *op.Security
+	}
+	policy := share.PolicyToken
+	if len(security) == 0 {
+		policy = share.PolicyPublic
+	}
+	h.HandlerFunc(method, openapi.Pattern(path), h.mockHandler(doc, op),
+		&RouteOptions{
+			Name:        op.OperationID,
+			Description: op.Summary,
+			Tags:        op.Tags,
+			Policy:      policy,
+		})
+	return nil
+}
+
+func (h *Handler) mockHandler(
+	doc *openapi.Document, op *openapi.Operation) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		verr := &ValidationError{}
+
+		// Parameters
+		params := httprouter.ParamsFromContext(r.Context())
+		query := r.URL.Query()
+		for _, p := range op.Parameters {
+			value, ok := "", false
+			switch p.In {
+			case openapi.InPath:
+				value = params.ByName(p.Name)
+				ok = value != ""
+			case openapi.InQuery:
+				ok = query.Has(p.Name)
+				value = query.Get(p.Name)
+			default:
+				// Headers and cookies are not validated
+				continue
+			}
+			if !ok {
+				if p.Required {
+					verr.Add(p.Name, "is required")
+				}
+				continue
+			}
+			if p.Schema == nil {
+				continue
+			}
+			for _, e := range doc.Root(p.Schema).Validate(
+				paramValue(p.Schema, value)) {
+				verr.Add(p.Name, e.Message)
+			}
+		}
+
+		// Request body
+		if op.RequestBody != nil {
+			b, err := h.GetBody(r)
+			if err != nil {
+				h.JSON(http.StatusInternalServerError, w, r, err)
+				return
+			}
+			mt, ok := op.RequestBody.Content["application/json"]
+			if len(b) == 0 {
+				if op.RequestBody.Required {
+					verr.Add(schema.Root, "is required")
+				}
+			} else if ok && mt.Schema != nil {
+				errs, err := doc.Root(mt.Schema).ValidateJSON(b)
+				if err != nil {
+					h.JSON(http.StatusBadRequest, w, r, err)
+					return
+				}
+				verr.Errors = append(verr.Errors,
+					schemaValidationError(errs).Errors...)
+			}
+		}
+
+		if len(verr.Errors) > 0 {
+			h.JSON(http.StatusUnprocessableEntity, w, r, verr)
+			return
+		}
+
+		code, resp := mockResponse(op, r.Header.Get(HeaderPrefer))
+		if resp == nil || len(resp.Content) == 0 {
+			w.WriteHeader(code)
+			return
+		}
+		contentType, mt := mockContent(resp)
+		v := mt.Example
+		if v == nil && len(mt.Examples) > 0 {
+			names := make([]string, 0, len(mt.Examples))
+			for name := range mt.Examples {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			v = mt.Examples[names[0]].Value
+		}
+		if v == nil && mt.Schema != nil {
+			v = doc.Root(mt.Schema).Example()
+		}
+		if s, ok := v.(string); ok && contentType != "application/json" {
+			h.Write(code, contentType, w, r, []byte(s))
+			return
+		}
+		h.JSON(code, w, r, v)
+	}
+}
+
+// paramValue converts the param to the type in the schema,
+// the value is returned as is if it can't be converted
+func paramValue(s *schema.Schema, value string) interface{} {
+	switch {
+	case s.Type.Has("string"):
+		return value
+	case s.Type.Has("integer"), s.Type.Has("number"):
+		f, err := strconv.ParseFloat(value, 64)
+		if err == nil {
+			return f
+		}
+	case s.Type.Has("boolean"):
+		b, err := strconv.ParseBool(value)
+		if err == nil {
+			return b
+		}
+	case s.Type.Has("array"), s.Type.Has("object"):
+		var v interface{}
+		err := json.Unmarshal([]byte(value), &v)
+		if err == nil {
+			return v
+		}
+	}
+	return value
+}
+
+// mockResponse selects the response with the preferred status code,
+// otherwise the first success response, or the default response.
+// Range keys like "2XX" match any code in the range,
+// explicit codes take precedence
+func mockResponse(op *openapi.Operation, prefer string) (int, *openapi.Response) {
+	for _, pref := range strings.Split(prefer, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pref), "=", 2)
+		if len(kv) == 2 && kv[0] == "code" {
+			code, err := strconv.Atoi(kv[1])
+			if err == nil {
+				if resp := responseFor(op, code); resp != nil {
+					return code, resp
+				}
+			}
+		}
+	}
+
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, key := range codes {
+		code, err := strconv.Atoi(key)
+		if err == nil && code >= 200 && code < 300 {
+			return code, op.Responses[key]
+		}
+	}
+	if resp := responseFor(op, http.StatusOK); resp != nil {
+		return http.StatusOK, resp
+	}
+	return http.StatusOK, op.Responses["default"]
+}
+
+// responseFor returns the response for code, or the response for its range
+func responseFor(op *openapi.Operation, code int) *openapi.Response {
+	if resp, ok := op.Responses[strconv.Itoa(code)]; ok {
+		return resp
+	}
+	for _, key := range []string{
+		fmt.Sprintf("%dXX", code/100), fmt.Sprintf("%dxx", code/100),
+	} {
+		if resp, ok := op.Responses[key]; ok {
+			return resp
+		}
+	}
+	return nil
+}
+
+// mockContent prefers JSON content
+func mockContent(resp *openapi.Response) (string, openapi.MediaType) {
+	if mt, ok := resp.Content["application/json"]; ok {
+		return "application/json", mt
+	}
+	types := make([]string, 0, len(resp.Content))
+	for contentType := range resp.Content {
+		types = append(types, contentType)
+	}
+	sort.Strings(types)
+	return types[0], resp.Content[types[0]]
+}
@@ -0,0 +1,206 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/openapi"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+const mockDoc = `{
+	"openapi": "3.1.0",
+	"info": {"title": "mock", "version": "1"},
+	"security": [{"token": []}],
+	"paths": {
+		"/items/{id}": {
+			"get": {
+				"operationId": "items.get",
+				"tags": ["items"],
+				"parameters": [
+					{"name": "id", "in": "path", "required": true,
+						"schema": {"type": "integer", "minimum": 1}},
+					{"name": "dry", "in": "query", "schema": {"type": "boolean"}}
+				],
+				"responses": {
+					"200": {"description": "OK", "content": {"application/json": {
+						"schema": {"$ref": "#/components/schemas/item"}
+					}}},
+					"404": {"description": "Not found", "content": {"application/json": {
+						"example": {"message": "not found"}
+					}}}
+				}
+			}
+		},
+		"/items": {
+			"post": {
+				"operationId": "items.create",
+				"security": [],
+				"requestBody": {"required": true, "content": {"application/json": {
+					"schema": {"$ref": "#/components/schemas/item"}
+				}}},
+				"responses": {"204": {"description": "Created"}}
+			}
+		}
+	},
+	"components": {
+		"schemas": {
+			"item": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string", "minLength": 8},
+					"qty": {"type": "integer", "minimum": 1, "examples": [3]}
+				}
+			}
+		}
+	}
+}`
+
+func TestMock(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	doc, err := openapi.Parse([]byte(mockDoc))
+	require.NoError(t, err)
+	require.NoError(t, h.Mock(doc))
+
+	// Registry
+	route, ok := h.Registry.Lookup("GET", "/items/1")
+	require.True(t, ok)
+	require.Equal(t, "items.get", route.Name)
+	require.Equal(t, share.PolicyToken, route.Policy)
+	route, ok = h.Registry.Lookup("POST", "/items")
+	require.True(t, ok)
+	require.Equal(t, share.PolicyPublic, route.Policy)
+
+	serve := func(method, target, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		for k := range header {
+			req.Header.Set(k, header.Get(k))
+		}
+		rec := httptest.NewRecorder()
+		h.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Generated from the schema
+	rec := serve("GET", "/items/1?dry=true", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"name": "stringxx", "qty": 3}`, rec.Body.String())
+
+	// Example for the preferred status code
+	rec = serve("GET", "/items/1", "",
+		http.Header{handler.HeaderPrefer: {"code=404"}})
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.JSONEq(t, `{"message": "not found"}`, rec.Body.String())
+
+	// Invalid params
+	rec = serve("GET", "/items/0?dry=maybe", "", nil)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	resp := share.ValidationErrResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Errors, 2)
+	require.Equal(t, "id", resp.Errors[0].Field)
+	require.Equal(t, "dry", resp.Errors[1].Field)
+
+	// Request body
+	rec = serve("POST", "/items", `{"name": "longenough"}`, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Empty(t, rec.Body.String())
+	rec = serve("POST", "/items", `{"qty": 0}`, nil)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = serve("POST", "/items", "", nil)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = serve("POST", "/items", "{", nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Conflicting routes
+	h = handler.NewHandler(conf)
+	defer h.Cleanup()
+	doc, err = openapi.Parse([]byte(mockDoc))
+	require.NoError(t, err)
+	doc.Paths["/items/{name}"] = doc.Paths["/items/{id}"]
+	require.Error(t, h.Mock(doc))
+}
+
+// mockDoc30 uses OpenAPI 3.0 schema keywords, and a response range
+const mockDoc30 = `{
+	"openapi": "3.0.3",
+	"info": {"title": "mock", "version": "1"},
+	"paths": {
+		"/items": {
+			"post": {
+				"operationId": "items.create",
+				"requestBody": {"required": true, "content": {"application/json": {
+					"schema": {
+						"type": "object",
+						"properties": {
+							"qty": {"type": "integer",
+								"minimum": 0, "exclusiveMinimum": true},
+							"note": {"type": "string", "nullable": true}
+						}
+					}
+				}}},
+				"responses": {
+					"2XX": {"description": "Created", "content": {"application/json": {
+						"example": {"id": 1}
+					}}},
+					"4XX": {"description": "Error", "content": {"application/json": {
+						"example": {"message": "error"}
+					}}}
+				}
+			}
+		}
+	}
+}`
+
+func TestMock30(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	_, err = openapi.Parse([]byte(strings.Replace(mockDoc30, "3.0.3", "2.0", 1)))
+	require.Error(t, err)
+	doc, err := openapi.Parse([]byte(mockDoc30))
+	require.NoError(t, err)
+	qty := doc.Paths["/items"]["post"].RequestBody.Content["application/json"].
+		Schema.Properties["qty"]
+	require.Nil(t, qty.Minimum)
+	require.Equal(t, 0.0, *qty.ExclusiveMinimum)
+	require.NoError(t, h.Mock(doc))
+
+	serve := func(body, prefer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/items?token=123",
+			strings.NewReader(body))
+		if prefer != "" {
+			req.Header.Set(handler.HeaderPrefer, prefer)
+		}
+		rec := httptest.NewRecorder()
+		h.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Range responses
+	rec := serve(`{"qty": 1, "note": null}`, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"id": 1}`, rec.Body.String())
+	rec = serve(`{"qty": 1}`, "code=201")
+	require.Equal(t, http.StatusCreated, rec.Code)
+	rec = serve(`{"qty": 1}`, "code=409")
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.JSONEq(t, `{"message": "error"}`, rec.Body.String())
+
+	// Exclusive minimum
+	rec = serve(`{"qty": 0}`, "")
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
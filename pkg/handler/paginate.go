@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mozey/httprouter-util/pkg/share"
+)
+
+// Query params used for pagination
+const (
+	QueryLimit  = "limit"
+	QueryCursor = "cursor"
+)
+
+type PaginatorOptions struct {
+	// DefaultLimit is used if the limit param is not set, default 20
+	DefaultLimit int
+	// MaxLimit is the largest limit clients may request, default 100
+	MaxLimit int
+	// Secret signs cursors so clients can't forge them.
+	// If empty a random secret is used,
+	// and cursors are invalid after the server restarts
+	Secret []byte
+}
+
+// Paginator parses page requests, and creates opaque signed cursors
+type Paginator struct {
+	defaultLimit int
+	maxLimit     int
+	secret       []byte
+}
+
+// Page request parsed from the query string
+type Page struct {
+	Limit int
+	// Position decoded from the cursor, empty for the first page
+	Position string
+}
+
+// NewPaginator creates a new paginator
+func NewPaginator(o *PaginatorOptions) (p *Paginator) {
+	if o == nil {
+		o = &PaginatorOptions{}
+	}
+	p = &Paginator{}
+	p.defaultLimit = o.DefaultLimit
+	if p.defaultLimit <= 0 {
+		p.defaultLimit = 20
+	}
+	p.maxLimit = o.MaxLimit
+	if p.maxLimit <= 0 {
+		p.maxLimit = 100
+	}
+	if p.defaultLimit > p.maxLimit {
+		p.defaultLimit = p.maxLimit
+	}
+	p.secret = o.Secret
+	if len(p.secret) == 0 {
+		p.secret = make([]byte, 32)
+		_, _ = rand.Read(p.secret)
+	}
+	return p
+}
+
+// Parse the limit and cursor query params.
+// Returns *ValidationError if a param is invalid
+func (p *Paginator) Parse(r *http.Request) (page Page, err error) {
+	query := r.URL.Query()
+	verr := &ValidationError{}
+
+	page.Limit = p.defaultLimit
+	if s := query.Get(QueryLimit); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil {
+			verr.Add(QueryLimit, "must be an integer")
+		} else if limit < 1 || limit > p.maxLimit {
+			verr.Add(QueryLimit, fmt.Sprintf(
+				"must be between 1 and %d", p.maxLimit))
+		} else {
+			page.Limit = limit
+		}
+	}
+
+	if cursor := query.Get(QueryCursor); cursor != "" {
+		position, ok := p.decode(cursor)
+		if !ok {
+			verr.Add(QueryCursor, "is invalid")
+		} else {
+			page.Position = position
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return page, verr
+	}
+	return page, nil
+}
+
+// Cursor encodes and signs the position,
+// e.g. the ID of the last item on the page
+func (p *Paginator) Cursor(position string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(position))
+	return payload + "." + p.sign(payload)
+}
+
+func (p *Paginator) sign(payload string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	_, _ = mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (p *Paginator) decode(cursor string) (position string, ok bool) {
+	i := strings.LastIndex(cursor, ".")
+	if i < 0 {
+		return "", false
+	}
+	payload, sig := cursor[:i], cursor[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(p.sign(payload))) {
+		return "", false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+// SetLinks sets the RFC 8288 Link header with the first and next pages,
+// next is the position of the next page, empty if this is the last page.
+// Call it before writing the response, e.g. with Stream
+func (p *Paginator) SetLinks(w http.ResponseWriter, r *http.Request,
+	page Page, next string) {
+
+	links := []string{
+		fmt.Sprintf(`<%s>; rel="first"`, p.pageURL(r, page, "")),
+	}
+	if next != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`,
+			p.pageURL(r, page, p.Cursor(next))))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// pageURL is the request URL with the cursor replaced
+func (p *Paginator) pageURL(r *http.Request, page Page, cursor string) string {
+	u := url.URL{Path: r.URL.Path}
+	query := r.URL.Query()
+	query.Set(QueryLimit, strconv.Itoa(page.Limit))
+	if cursor == "" {
+		query.Del(QueryCursor)
+	} else {
+		query.Set(QueryCursor, cursor)
+	}
+	// Links may be logged by proxies, don't include the auth token.
+	// Clients must set their own token when following links
+	query.Del(QueryToken)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Response sets the Link header, and returns the standard envelope
+// to pass to JSON, next is the position of the next page
+func (p *Paginator) Response(w http.ResponseWriter, r *http.Request,
+	page Page, items interface{}, next string) share.PageResponse {
+
+	p.SetLinks(w, r, page, next)
+	resp := share.PageResponse{
+		Items: items,
+		Limit: page.Limit,
+	}
+	if next != "" {
+		resp.NextCursor = p.Cursor(next)
+	}
+	return resp
+}
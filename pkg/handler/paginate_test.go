@@ -0,0 +1,74 @@
+package handler_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginator(t *testing.T) {
+	p := handler.NewPaginator(&handler.PaginatorOptions{
+		MaxLimit: 50,
+		Secret:   []byte("secret"),
+	})
+
+	// Defaults
+	req := httptest.NewRequest("GET", "/items?token=123", nil)
+	page, err := p.Parse(req)
+	require.NoError(t, err)
+	require.Equal(t, 20, page.Limit)
+	require.Empty(t, page.Position)
+
+	// Limit validation
+	for _, limit := range []string{"0", "51", "abc"} {
+		req = httptest.NewRequest("GET", "/items?limit="+limit, nil)
+		_, err = p.Parse(req)
+		verr, ok := err.(*handler.ValidationError)
+		require.True(t, ok, limit)
+		require.Equal(t, "limit", verr.Errors[0].Field)
+	}
+
+	// Cursor round trip
+	cursor := p.Cursor("item-42")
+	req = httptest.NewRequest("GET",
+		"/items?limit=10&cursor="+url.QueryEscape(cursor), nil)
+	page, err = p.Parse(req)
+	require.NoError(t, err)
+	require.Equal(t, 10, page.Limit)
+	require.Equal(t, "item-42", page.Position)
+
+	// Tampered cursors and cursors signed with another secret are rejected
+	other := handler.NewPaginator(nil)
+	for _, c := range []string{
+		cursor[1:], "x" + cursor, "item-42", other.Cursor("item-42")} {
+		req = httptest.NewRequest("GET",
+			"/items?cursor="+url.QueryEscape(c), nil)
+		_, err = p.Parse(req)
+		verr, ok := err.(*handler.ValidationError)
+		require.True(t, ok, c)
+		require.Equal(t, "cursor", verr.Errors[0].Field)
+	}
+
+	// Link header and envelope, the token is not included in links
+	req = httptest.NewRequest("GET", "/items?limit=10&token=123", nil)
+	page, err = p.Parse(req)
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	resp := p.Response(rec, req, page, []int{1, 2}, "item-10")
+	require.Equal(t, 10, resp.Limit)
+	require.Equal(t, p.Cursor("item-10"), resp.NextCursor)
+	require.Equal(t,
+		`</items?limit=10>; rel="first", `+
+			`</items?cursor=`+url.QueryEscape(resp.NextCursor)+
+			`&limit=10>; rel="next"`,
+		rec.Header().Get("Link"))
+
+	// Last page has no next link
+	rec = httptest.NewRecorder()
+	resp = p.Response(rec, req, page, []int{}, "")
+	require.Empty(t, resp.NextCursor)
+	require.Equal(t, `</items?limit=10>; rel="first"`, rec.Header().Get("Link"))
+}
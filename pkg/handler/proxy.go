@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/rs/zerolog/log"
+)
+
+// ProxyMethods are registered by Proxy
+var ProxyMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost,
+	http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// ProxyNoRetries disables retries, see ProxyOptions.Retries
+const ProxyNoRetries = -1
+
+// QueryToken is the query param with the auth token,
+// it's removed from links and upstream requests
+const QueryToken = "token"
+
+// ProxyOptions for Proxy and ProxyHandler, the options are copied
+// when the handler is created
+type ProxyOptions struct {
+	// Upstream requests are sent to, the upstream path is prepended to the
+	// request path, e.g. "http://localhost:9000/v1"
+	Upstream *url.URL
+	// StripPrefix is removed from the request path
+	StripPrefix string
+	// Rewrite the request path after the prefix is removed, optional
+	Rewrite func(path string) string
+	// SetHeaders are set on upstream requests, e.g. credentials
+	SetHeaders http.Header
+	// RemoveHeaders are removed from upstream requests, e.g. "Cookie"
+	RemoveHeaders []string
+	// RemoveQuery params are removed from upstream requests.
+	// The QueryToken param is always removed, unless ForwardToken is set
+	RemoveQuery []string
+	// ForwardToken passes the auth token on to the upstream
+	ForwardToken bool
+	// RemoveResponseHeaders are removed from upstream responses
+	RemoveResponseHeaders []string
+	// Timeout waiting for the upstream response headers, default 30s.
+	// It does not limit reading the body, see IdleTimeout.
+	// Ignored if Transport is set
+	Timeout time.Duration
+	// IdleTimeout between reads of the upstream response body,
+	// default Timeout. The request is cancelled if the upstream stalls,
+	// set a larger value for streams with long pauses, e.g. SSE
+	IdleTimeout time.Duration
+	// Retries of idempotent requests without a body, default 2.
+	// Requests are retried on connection errors,
+	// and 502, 503, or 504 responses from the upstream.
+	// Set ProxyNoRetries to disable retries
+	Retries int
+	// RetryBackoff is multiplied by the attempt number, default 100ms
+	RetryBackoff time.Duration
+	// Transport for upstream requests, default is a clone of
+	// http.DefaultTransport with the Timeout
+	Transport http.RoundTripper
+}
+
+// Proxy mounts the upstream under prefix, requests matching prefix/*path
+// are forwarded for each of the ProxyMethods.
+// The prefix is stripped, unless StripPrefix is set.
+// Each route is registered with the method appended to the route name
+func (h *Handler) Proxy(prefix string, o *ProxyOptions, ro *RouteOptions) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	copied := *o
+	if copied.StripPrefix == "" {
+		copied.StripPrefix = prefix
+	}
+	handler := h.ProxyHandler(&copied)
+	for _, method := range ProxyMethods {
+		var mro *RouteOptions
+		if ro != nil {
+			copied := *ro
+			if copied.Name != "" {
+				copied.Name += "." + strings.ToLower(method)
+			}
+			mro = &copied
+		}
+		h.HandlerFunc(method, prefix+"/*path", handler, mro)
+	}
+}
+
+// ProxyHandler forwards requests to the upstream.
+// The request ID is passed on with the X-Request-ID header.
+// Upstream errors are JSON responses,
+// 504 if the upstream timed out, otherwise 502
+func (h *Handler) ProxyHandler(po *ProxyOptions) http.HandlerFunc {
+	// Defaults are not set on the caller's options
+	copied := *po
+	o := &copied
+	if o.Timeout == 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.IdleTimeout == 0 {
+		o.IdleTimeout = o.Timeout
+	}
+	removeQuery := o.RemoveQuery
+	if !o.ForwardToken {
+		removeQuery = append([]string{QueryToken}, removeQuery...)
+	}
+	switch {
+	case o.Retries == 0:
+		o.Retries = 2
+	case o.Retries < 0:
+		o.Retries = 0
+	}
+	if o.RetryBackoff == 0 {
+		o.RetryBackoff = 100 * time.Millisecond
+	}
+	transport := o.Transport
+	if transport == nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.ResponseHeaderTimeout = o.Timeout
+		transport = t
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			out := pr.Out
+			path := strings.TrimPrefix(pr.In.URL.Path, o.StripPrefix)
+			if o.Rewrite != nil {
+				path = o.Rewrite(path)
+			}
+			if !strings.HasPrefix(path, "/") {
+				path = "/" + path
+			}
+			out.URL.Path, out.URL.RawPath = path, ""
+			if len(removeQuery) > 0 {
+				query := out.URL.Query()
+				for _, key := range removeQuery {
+					query.Del(key)
+				}
+				out.URL.RawQuery = query.Encode()
+			}
+			pr.SetURL(o.Upstream)
+			pr.SetXForwarded()
+
+			for _, key := range o.RemoveHeaders {
+				out.Header.Del(key)
+			}
+			for key, values := range o.SetHeaders {
+				out.Header[http.CanonicalHeaderKey(key)] = values
+			}
+			requestID, ok := pr.In.Context().Value(share.HeaderXRequestID).(string)
+			if ok {
+				out.Header.Set(share.HeaderXRequestID, requestID)
+			}
+		},
+		Transport: &retryTransport{
+			next: &idleTimeoutTransport{
+				next:    transport,
+				timeout: o.IdleTimeout,
+			},
+			retries: o.Retries,
+			backoff: o.RetryBackoff,
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			for _, key := range o.RemoveResponseHeaders {
+				resp.Header.Del(key)
+			}
+			// Upstream request ID is not used, see RequestID middleware
+			resp.Header.Del(share.HeaderXRequestID)
+			return nil
+		},
+		ErrorHandler: h.proxyError,
+	}
+	return proxy.ServeHTTP
+}
+
+// proxyError responds with a JSON error
+func (h *Handler) proxyError(w http.ResponseWriter, r *http.Request, err error) {
+	code, msg := http.StatusBadGateway, "upstream unavailable"
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		code, msg = http.StatusGatewayTimeout, "upstream timed out"
+	}
+	log.Ctx(r.Context()).Error().Err(err).Int("code", code).Msg("proxy")
+
+	resp := share.ErrResponse{Message: msg}
+	requestID, ok := r.Context().Value(share.HeaderXRequestID).(string)
+	if ok {
+		resp.RequestID = requestID
+	}
+	h.JSON(code, w, r, resp)
+}
+
+// retryTransport retries idempotent requests
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+func (t *retryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	retry := idempotent(r.Method) && (r.Body == nil || r.Body == http.NoBody)
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(r)
+		if !retry || attempt >= t.retries || r.Context().Err() != nil {
+			return resp, err
+		}
+		if err == nil {
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable,
+				http.StatusGatewayTimeout:
+				_ = resp.Body.Close()
+			default:
+				return resp, nil
+			}
+		}
+		log.Ctx(r.Context()).Warn().Err(err).Int("attempt", attempt+1).
+			Msg("proxy retry")
+
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(time.Duration(attempt+1) * t.backoff):
+		}
+	}
+}
+
+// idleTimeoutTransport cancels the upstream request
+// if the response body is not read for longer than timeout
+type idleTimeoutTransport struct {
+	next    http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *idleTimeoutTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get("Upgrade") != "" {
+		// The proxy copies upgraded connections, e.g. WebSockets
+		return t.next.RoundTrip(r)
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	resp, err := t.next.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return resp, err
+	}
+	resp.Body = &idleTimeoutBody{
+		ReadCloser: resp.Body,
+		timer:      time.AfterFunc(t.timeout, cancel),
+		timeout:    t.timeout,
+		cancel:     cancel,
+	}
+	return resp, nil
+}
+
+// idleTimeoutBody resets the timer on every read
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timer   *time.Timer
+	timeout time.Duration
+	cancel  context.CancelFunc
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	if n > 0 {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// idempotent methods can be retried, see RFC 9110 section 9.2.2
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	// Upstream echoes the request
+	var failures, attempts int32
+	upstream := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			if atomic.AddInt32(&failures, -1) >= 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if r.URL.Path == "/v1/slow" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			if r.URL.Path == "/v1/stall" {
+				// Headers and part of the body, then nothing
+				_, _ = w.Write([]byte("partial"))
+				w.(http.Flusher).Flush()
+				time.Sleep(500 * time.Millisecond)
+				_, _ = w.Write([]byte(" rest"))
+				return
+			}
+			w.Header().Set("Server", "upstream")
+			w.Header().Set(share.HeaderXRequestID, "upstream")
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"path":       r.URL.Path,
+				"query":      r.URL.RawQuery,
+				"auth":       r.Header.Get("Authorization"),
+				"cookie":     r.Header.Get("Cookie"),
+				"request_id": r.Header.Get(share.HeaderXRequestID),
+			})
+		}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL + "/v1")
+	require.NoError(t, err)
+
+	o := &handler.ProxyOptions{
+		Upstream: u,
+		Rewrite: func(path string) string {
+			return strings.Replace(path, "/old/", "/new/", 1)
+		},
+		SetHeaders:            http.Header{"Authorization": {"Bearer secret"}},
+		RemoveHeaders:         []string{"Cookie"},
+		RemoveQuery:           []string{"remove"},
+		RemoveResponseHeaders: []string{"Server"},
+		Timeout:               50 * time.Millisecond,
+		RetryBackoff:          time.Millisecond,
+	}
+	h.Proxy("/up/", o, &handler.RouteOptions{Name: "up"})
+	// Defaults are not set on the options
+	require.Empty(t, o.StripPrefix)
+	require.Zero(t, o.Retries)
+	o.Retries = handler.ProxyNoRetries
+	h.Proxy("/once/", o, nil)
+	h.Proxy("/fwd/", &handler.ProxyOptions{
+		Upstream: u, ForwardToken: true}, nil)
+	route, ok := h.Registry.Lookup("POST", "/up/items")
+	require.True(t, ok)
+	require.Equal(t, "up.post", route.Name)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Cookie", "session=1")
+		req = req.WithContext(context.WithValue(
+			req.Context(), share.HeaderXRequestID, "abc"))
+		rec := httptest.NewRecorder()
+		h.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Path, headers, and request ID.
+	// The token is removed by default
+	rec := serve("GET", "/up/old/items?token=123&a=b&remove=1")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{
+		"path": "/v1/new/items",
+		"query": "a=b",
+		"auth": "Bearer secret",
+		"cookie": "",
+		"request_id": "abc"
+	}`, rec.Body.String())
+	require.Empty(t, rec.Header().Get("Server"))
+	require.Empty(t, rec.Header().Get(share.HeaderXRequestID))
+	rec = serve("GET", "/fwd/items?token=123")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"query":"token=123"`)
+
+	// Upstream stalls after sending part of the body
+	start := time.Now()
+	rec = serve("GET", "/up/stall")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "partial", rec.Body.String())
+	require.True(t, time.Since(start) < 400*time.Millisecond)
+
+	// Idempotent requests are retried
+	atomic.StoreInt32(&failures, 2)
+	atomic.StoreInt32(&attempts, 0)
+	rec = serve("GET", "/up/items")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	atomic.StoreInt32(&failures, 1)
+	atomic.StoreInt32(&attempts, 0)
+	rec = serve("GET", "/once/items")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	atomic.StoreInt32(&failures, 1)
+	atomic.StoreInt32(&attempts, 0)
+	rec = serve("POST", "/up/items")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	atomic.StoreInt32(&failures, 0)
+
+	// Upstream errors
+	rec = serve("POST", "/up/slow")
+	require.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	resp := share.ErrResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "abc", resp.RequestID)
+
+	upstream.Close()
+	rec = serve("GET", "/up/items")
+	require.Equal(t, http.StatusBadGateway, rec.Code)
+	resp = share.ErrResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, "abc", resp.RequestID)
+}
@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"bytes"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/mozey/httprouter-util/pkg/share"
 	"github.com/pkg/errors"
@@ -15,7 +18,15 @@ import (
 
 // JSON can be used by route handlers to respond to requests
 func (h *Handler) JSON(code int, w http.ResponseWriter, r *http.Request, resp interface{}) {
+	h.JSONWithOptions(code, w, r, resp, nil)
+}
+
+// JSONWithOptions is like JSON, options are used for conditional requests
+func (h *Handler) JSONWithOptions(code int, w http.ResponseWriter,
+	r *http.Request, resp interface{}, o *ResponseOptions) {
+
 	ctx := r.Context()
+	query := r.URL.Query()
 
 	// Default message
 	msg := http.StatusText(code)
@@ -28,29 +39,46 @@ func (h *Handler) JSON(code int, w http.ResponseWriter, r *http.Request, resp in
 		logEvent = log.Ctx(ctx).Error()
 	}
 
-	// Marshal indented response JSON,
-	// uses type switch to handle different resp types
-	var b []byte
-	var respStr interface{}
+	// Encode response JSON once into a pooled buffer, indented unless
+	// the client asked for compact output, see pretty.
+	// Uses type switch to handle different resp types
+	buf := getBuffer()
+	defer putBuffer(buf)
+	indented := pretty(r, query)
 	var err error
-	indent := "    "
 	switch v := resp.(type) {
 	case share.JSONRaw:
-		respStr = resp
+		buf.WriteString(string(v))
 
 	case string:
 		msg = v
-		b, err = json.MarshalIndent(share.Response{Message: msg}, "", indent)
-		if err != nil {
-			log.Ctx(ctx).Error().Stack().Err(errors.WithStack(err)).Msg("")
-			respStr = err.Error()
-			break
+		err = buf.encode(share.Response{Message: msg}, indented, nil)
+
+	case *ValidationError:
+		// Logged as an error without the stack,
+		// the client must fix the request
+		msg = v.Error()
+		logEvent = log.Ctx(ctx).Error()
+		if code < 400 {
+			code = http.StatusUnprocessableEntity
+		}
+		errResp := share.ValidationErrResponse{
+			Message: "validation failed",
+			Errors:  v.Errors,
 		}
-		respStr = string(b)
+		requestID, ok := r.Context().Value(share.HeaderXRequestID).(string)
+		if ok {
+			errResp.RequestID = requestID
+		}
+		err = buf.encode(errResp, indented, nil)
 
 	case error:
 		msg = v.Error()
 		logEvent = log.Ctx(ctx).Error().Stack().Err(v)
+		if _, ok := errors.Cause(v).(*http.MaxBytesError); ok {
+			// Request body exceeds the limit set by the MaxBytes middleware
+			code = http.StatusRequestEntityTooLarge
+		}
 		if code < 400 {
 			// The caller should pass in an error code if resp is an error,
 			// if not then override code
@@ -61,37 +89,104 @@ func (h *Handler) JSON(code int, w http.ResponseWriter, r *http.Request, resp in
 		if ok {
 			errResp.RequestID = requestID
 		}
-		b, err = json.MarshalIndent(errResp, "", indent)
-		if err != nil {
-			log.Ctx(ctx).Error().Stack().Err(errors.WithStack(err)).Msg("")
-			respStr = err.Error()
-			break
-		}
-		respStr = string(b)
+		err = buf.encode(errResp, indented, nil)
 
 	default:
-		b, err = json.MarshalIndent(resp, "", indent)
-		if err != nil {
-			log.Ctx(ctx).Error().Stack().Err(errors.WithStack(err)).Msg("")
-			respStr = err.Error()
-			break
+		// Responses with a message implement share.Messenger
+		if m, ok := resp.(share.Messenger); ok && m.ResponseMessage() != "" {
+			msg = m.ResponseMessage()
 		}
-		// Rather than using reflection or type casting,
-		// unmarshal the response to determine if a message property was set
-		r := share.Response{}
-		err := json.Unmarshal(b, &r)
-		if err == nil {
-			if r.Message != "" {
-				msg = r.Message
-			}
-		}
-		respStr = string(b)
+		// Only the fields selected by the client are included,
+		// error responses are never filtered
+		err = buf.encode(resp, indented,
+			parseFields(query.Get(QueryFields)))
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Stack().Err(err).Msg("")
+		buf.Reset()
+		buf.WriteString(err.Error())
+	}
+
+	// Output depends on the pretty parameter of the Accept header
+	Vary(w.Header(), "Accept")
+
+	// Conditional requests
+	if o != nil && code == http.StatusOK &&
+		notModified(w, r, buf.Bytes(), o) {
+		w.WriteHeader(http.StatusNotModified)
+		logResponse(r, logEvent, http.StatusNotModified, msg)
+		return
 	}
 
 	// Write headers
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
 	w.WriteHeader(code) // Must be called after w.Header().Set?
 
+	logResponse(r, logEvent, code, msg)
+
+	// Write response
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		log.Ctx(ctx).Error().Stack().Err(errors.WithStack(err)).Msg("")
+	}
+}
+
+// jsonBuffer is a buffer with an encoder that writes to it,
+// the encoder keeps internal buffers that are reused when pooled
+type jsonBuffer struct {
+	bytes.Buffer
+	enc *json.Encoder
+}
+
+// bufPool reduces allocations when encoding responses
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		buf := &jsonBuffer{}
+		buf.enc = json.NewEncoder(&buf.Buffer)
+		return buf
+	},
+}
+
+// maxPooledBuffer is the capacity above which buffers are not reused,
+// otherwise a few large responses keep memory allocated
+const maxPooledBuffer = 64 * 1024
+
+func getBuffer() *jsonBuffer {
+	buf := bufPool.Get().(*jsonBuffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *jsonBuffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufPool.Put(buf)
+}
+
+// Vary adds the request header name to the Vary response header,
+// unless it's already listed, e.g. by other middleware
+func Vary(header http.Header, name string) {
+	for _, v := range header.Values("Vary") {
+		for _, listed := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
+
+// tokenRe matches the auth token in the request query
+var tokenRe = regexp.MustCompile(`token=\w+`)
+
+// logResponse logs the request with the response status code
+func logResponse(
+	r *http.Request, logEvent *zerolog.Event, code int, msg string) {
+
+	ctx := r.Context()
+
 	// Some of the properties below are also set in the logrequest middleware,
 	// set them again in case this route does not call the middleware
 	l := log.Ctx(ctx)
@@ -107,8 +202,7 @@ func (h *Handler) JSON(code int, w http.ResponseWriter, r *http.Request, resp in
 	if err != nil {
 		query = err.Error()
 	}
-	var re = regexp.MustCompile(`token=\w+`)
-	query = re.ReplaceAllString(query, `token=xxx`)
+	query = tokenRe.ReplaceAllString(query, `token=xxx`)
 
 	logEvent.Int("code", code).
 		Str("method", r.Method).
@@ -116,16 +210,17 @@ func (h *Handler) JSON(code int, w http.ResponseWriter, r *http.Request, resp in
 		Str("request_query", query).
 		Str("remote_addr", r.RemoteAddr).
 		Msg(msg)
-
-	// Write response
-	_, err = fmt.Fprint(w, respStr)
-	if err != nil {
-		log.Ctx(ctx).Error().Stack().Err(errors.WithStack(err)).Msg("")
-	}
 }
 
 // Write response bytes with specified code and content type headers
 func (h *Handler) Write(code int, contentType string, w http.ResponseWriter, r *http.Request, b []byte) {
+	h.WriteWithOptions(code, contentType, w, r, b, nil)
+}
+
+// WriteWithOptions is like Write, options are used for conditional requests
+func (h *Handler) WriteWithOptions(code int, contentType string,
+	w http.ResponseWriter, r *http.Request, b []byte, o *ResponseOptions) {
+
 	ctx := r.Context()
 
 	if contentType == "" {
@@ -133,8 +228,20 @@ func (h *Handler) Write(code int, contentType string, w http.ResponseWriter, r *
 		contentType = "text/html; charset=UTF-8"
 	}
 
+	// Conditional requests
+	if o != nil && code == http.StatusOK && notModified(w, r, b, o) {
+		code = http.StatusNotModified
+		w.WriteHeader(code)
+		log.Ctx(ctx).Info().Int("code", code).
+			Str("method", r.Method).
+			Str("request_uri", r.RequestURI).
+			Msg(http.StatusText(code))
+		return
+	}
+
 	// Write headers
 	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
 	w.WriteHeader(code) // Must be called after w.Header().Set?
 
 	// Log request here instead of in middleware,
@@ -144,7 +251,7 @@ func (h *Handler) Write(code int, contentType string, w http.ResponseWriter, r *
 		Str("request_uri", r.RequestURI).
 		Msg(http.StatusText(code))
 
-	_, err := fmt.Fprint(w, string(b))
+	_, err := w.Write(b)
 	if err != nil {
 		log.Ctx(ctx).Error().Stack().Err(errors.WithStack(err)).Msg("")
 	}
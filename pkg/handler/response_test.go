@@ -1,17 +1,23 @@
 package handler_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"testing"
 
 	"github.com/mozey/httprouter-util/pkg/config"
 	"github.com/mozey/httprouter-util/pkg/handler"
 	"github.com/mozey/httprouter-util/pkg/share"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/stretchr/testify/require"
 )
@@ -80,4 +86,203 @@ func TestJSON(t *testing.T) {
 		"\"foo\": \"bar\"", "unexpected body")
 	require.Equal(t, rec.Header().Get("Content-Type"),
 		"application/json; charset=UTF-8")
+	require.Equal(t, strconv.Itoa(rec.Body.Len()),
+		rec.Header().Get("Content-Length"))
+}
+
+func TestJSONOutput(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	type Item struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	type Nested struct {
+		Message string `json:"message"`
+		Owner   Item   `json:"owner"`
+		Items   []Item `json:"items"`
+	}
+	resp := Nested{
+		Message: "foo",
+		Owner:   Item{ID: 1, Name: "bar"},
+		Items:   []Item{{ID: 2, Name: "baz"}, {ID: 3, Name: "qux"}},
+	}
+
+	// Pretty by default
+	req := httptest.NewRequest("GET", "/", nil)
+	rec := httptest.NewRecorder()
+	h.JSON(http.StatusOK, rec, req, resp)
+	require.Contains(t, rec.Body.String(), "\n    \"message\": \"foo\"")
+
+	// Compact with query param or Accept header
+	compact := `{"message":"foo","owner":{"id":1,"name":"bar"},` +
+		`"items":[{"id":2,"name":"baz"},{"id":3,"name":"qux"}]}`
+	req = httptest.NewRequest("GET", "/?pretty=false", nil)
+	rec = httptest.NewRecorder()
+	h.JSON(http.StatusOK, rec, req, resp)
+	require.Equal(t, compact, rec.Body.String())
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept", "text/html, application/json; pretty=false")
+	rec = httptest.NewRecorder()
+	h.JSON(http.StatusOK, rec, req, resp)
+	require.Equal(t, compact, rec.Body.String())
+	require.Equal(t, "Accept", rec.Header().Get("Vary"))
+
+	// Sparse fieldsets, nested paths and arrays
+	req = httptest.NewRequest("GET",
+		"/?pretty=false&fields=items.name,owner.id,missing", nil)
+	rec = httptest.NewRecorder()
+	h.JSON(http.StatusOK, rec, req, resp)
+	require.Equal(t,
+		`{"owner":{"id":1},"items":[{"name":"baz"},{"name":"qux"}]}`,
+		rec.Body.String())
+	req = httptest.NewRequest("GET",
+		"/?pretty=false&fields=owner.name,owner", nil)
+	rec = httptest.NewRecorder()
+	h.JSON(http.StatusOK, rec, req, []Nested{resp})
+	require.Equal(t, `[{"owner":{"id":1,"name":"bar"}}]`, rec.Body.String())
+
+	// Errors are not filtered
+	req = httptest.NewRequest("GET", "/?pretty=false&fields=foo", nil)
+	rec = httptest.NewRecorder()
+	h.JSON(http.StatusBadRequest, rec, req, errors.New("buz"))
+	require.Equal(t, `{"message":"buz","request_id":""}`, rec.Body.String())
+}
+
+type benchItem struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Tags    []string `json:"tags"`
+	Enabled bool     `json:"enabled"`
+}
+
+type benchResponse struct {
+	Message string      `json:"message"`
+	Items   []benchItem `json:"items"`
+}
+
+func (r benchResponse) ResponseMessage() string {
+	return r.Message
+}
+
+// withLogger returns a shallow copy of req with a new logger,
+// the logger context is updated when the response is written
+func withLogger(req *http.Request) *http.Request {
+	logger := zerolog.New(io.Discard)
+	return req.WithContext(logger.WithContext(req.Context()))
+}
+
+func benchSetup(b *testing.B) (h *handler.Handler, req *http.Request,
+	resp benchResponse) {
+
+	conf, err := config.LoadFile("dev")
+	require.NoError(b, err)
+	h = handler.NewHandler(conf)
+
+	req = httptest.NewRequest("GET", "/items?token=123&limit=50", nil)
+
+	resp.Message = "items"
+	for i := 0; i < 50; i++ {
+		resp.Items = append(resp.Items, benchItem{
+			ID: i, Name: fmt.Sprintf("item %d", i),
+			Tags: []string{"foo", "bar"}, Enabled: i%2 == 0,
+		})
+	}
+	return h, req, resp
+}
+
+// discardWriter is a ResponseWriter that does not allocate,
+// unlike httptest.ResponseRecorder
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardWriter) WriteHeader(code int) {}
+
+func BenchmarkJSON(b *testing.B) {
+	h, req, resp := benchSetup(b)
+	w := &discardWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for k := range w.header {
+			delete(w.header, k)
+		}
+		h.JSON(http.StatusOK, w, withLogger(req), resp)
+	}
+}
+
+// legacyJSON is the previous implementation of JSON,
+// kept to compare performance
+func legacyJSON(code int, w http.ResponseWriter, r *http.Request,
+	resp interface{}) {
+
+	ctx := r.Context()
+	msg := http.StatusText(code)
+	logEvent := log.Ctx(ctx).Info()
+	b, err := json.MarshalIndent(resp, "", "    ")
+	if err != nil {
+		return
+	}
+	m := share.Response{}
+	err = json.Unmarshal(b, &m)
+	if err == nil && m.Message != "" {
+		msg = m.Message
+	}
+	respStr := string(b)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(code)
+	l := log.Ctx(ctx)
+	l.UpdateContext(func(c zerolog.Context) zerolog.Context {
+		return c.
+			Str("method", r.Method).
+			Str("request_path", r.URL.Path)
+	})
+	query, err := url.QueryUnescape(r.URL.RawQuery)
+	if err != nil {
+		query = err.Error()
+	}
+	var re = regexp.MustCompile(`token=\w+`)
+	query = re.ReplaceAllString(query, `token=xxx`)
+	logEvent.Int("code", code).
+		Str("method", r.Method).
+		Str("request_path", r.URL.Path).
+		Str("request_query", query).
+		Str("remote_addr", r.RemoteAddr).
+		Msg(msg)
+	_, _ = fmt.Fprint(w, respStr)
+}
+
+func BenchmarkJSONLegacy(b *testing.B) {
+	_, req, resp := benchSetup(b)
+	w := &discardWriter{header: http.Header{}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for k := range w.header {
+			delete(w.header, k)
+		}
+		legacyJSON(http.StatusOK, w, withLogger(req), resp)
+	}
+}
+
+func TestVary(t *testing.T) {
+	header := http.Header{}
+	header.Set("Vary", "Origin, accept")
+	handler.Vary(header, "Accept")
+	handler.Vary(header, "Accept-Encoding")
+	handler.Vary(header, "Accept-Encoding")
+	require.Equal(t, []string{"Origin, accept", "Accept-Encoding"},
+		header.Values("Vary"))
 }
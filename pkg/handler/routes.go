@@ -0,0 +1,241 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/mozey/httprouter-util/pkg/share"
+)
+
+// RouteOptions describe a route in the Registry
+type RouteOptions struct {
+	Name        string
+	Description string
+	Tags        []string
+	// Policy is share.PolicyToken if empty
+	Policy string
+	// SkipMaxBytes is set for routes that limit the request body themselves,
+	// e.g. routes wrapped with Upload, see Registry.SkipMaxBytes
+	SkipMaxBytes bool
+
+	// Request and Response are zero values of the types used by the
+	// handler, e.g. share.HelloRequest{}, they are documented with
+	// schemas generated by reflection, see OpenAPI
+	Request  interface{}
+	Response interface{}
+	// RequestSchema and ResponseSchema are used instead of the types,
+	// e.g. for routes wrapped with Schema
+	RequestSchema  *schema.Schema
+	ResponseSchema *schema.Schema
+}
+
+// Registry of routes registered on the handler, see Handle.
+// Middleware can use it to look up the route for a request, e.g. Public
+type Registry struct {
+	mu      sync.RWMutex
+	routes  []share.Route
+	options []*RouteOptions
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Add a route to the registry
+func (reg *Registry) Add(method, pattern string, o *RouteOptions) {
+	if o == nil {
+		o = &RouteOptions{}
+	}
+	route := share.Route{
+		Method:      method,
+		Pattern:     pattern,
+		Name:        o.Name,
+		Description: o.Description,
+		Tags:        o.Tags,
+		Policy:      o.Policy,
+	}
+	if route.Policy == "" {
+		route.Policy = share.PolicyToken
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.routes = append(reg.routes, route)
+	reg.options = append(reg.options, o)
+}
+
+// Routes sorted by pattern and method
+func (reg *Registry) Routes() []share.Route {
+	routes, _ := reg.sorted()
+	return routes
+}
+
+// sorted returns the routes and options sorted by pattern and method
+func (reg *Registry) sorted() ([]share.Route, []*RouteOptions) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	index := make([]int, len(reg.routes))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		a, b := reg.routes[index[i]], reg.routes[index[j]]
+		if a.Pattern == b.Pattern {
+			return a.Method < b.Method
+		}
+		return a.Pattern < b.Pattern
+	})
+	routes := make([]share.Route, len(index))
+	options := make([]*RouteOptions, len(index))
+	for i, k := range index {
+		routes[i] = reg.routes[k]
+		options[i] = reg.options[k]
+		if options[i] == nil {
+			options[i] = &RouteOptions{}
+		}
+	}
+	return routes, options
+}
+
+// Lookup the route matching the request method and path,
+// the router does not allow conflicting patterns so there is one match
+func (reg *Registry) Lookup(method, path string) (route share.Route, ok bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	i, _ := reg.lookup(method, path)
+	if i < 0 {
+		return route, false
+	}
+	return reg.routes[i], true
+}
+
+// lookup returns the index of the route, or -1.
+// Allowed is true if a route matches the path for any method.
+// Callers must hold the lock
+func (reg *Registry) lookup(method, path string) (i int, allowed bool) {
+	for i, rt := range reg.routes {
+		if !matchPattern(rt.Pattern, path) {
+			continue
+		}
+		if rt.Method == method {
+			return i, true
+		}
+		allowed = true
+	}
+	return -1, allowed
+}
+
+// contextKeyRoute is set on the request context by WithRoute
+type contextKeyRoute struct{}
+
+// routeMatch is the result of looking up the route for a request
+type routeMatch struct {
+	reg     *Registry
+	method  string
+	path    string
+	i       int
+	allowed bool
+}
+
+// WithRoute looks up the route for the request once,
+// and sets it on the request context for Public and SkipMaxBytes.
+// Call it after the path is rewritten, e.g. by version middleware
+func (reg *Registry) WithRoute(r *http.Request) *http.Request {
+	m := reg.match(r)
+	return r.WithContext(context.WithValue(r.Context(), contextKeyRoute{}, m))
+}
+
+// match returns the route set by WithRoute,
+// or looks it up if the request does not have it.
+// Callers must not hold the lock
+func (reg *Registry) match(r *http.Request) routeMatch {
+	m, ok := r.Context().Value(contextKeyRoute{}).(routeMatch)
+	if ok && m.reg == reg && m.method == r.Method && m.path == r.URL.Path {
+		return m
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	m = routeMatch{reg: reg, method: r.Method, path: r.URL.Path}
+	m.i, m.allowed = reg.lookup(r.Method, r.URL.Path)
+	return m
+}
+
+// Public returns true if the route for the request has share.PolicyPublic,
+// use it as the Skipper for auth middleware.
+// Requests without a route for the method are public,
+// the router replies with 405 and the allowed methods for the path,
+// or to OPTIONS requests, e.g. CORS preflight requests
+func (reg *Registry) Public(r *http.Request) bool {
+	m := reg.match(r)
+	if m.i < 0 {
+		return m.allowed || r.Method == http.MethodOptions
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.routes[m.i].Policy == share.PolicyPublic
+}
+
+// SkipMaxBytes returns true if the route for the request sets
+// RouteOptions.SkipMaxBytes, use it as the Skipper for MaxBytes middleware
+func (reg *Registry) SkipMaxBytes(r *http.Request) bool {
+	m := reg.match(r)
+	if m.i < 0 {
+		return false
+	}
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.options[m.i] != nil && reg.options[m.i].SkipMaxBytes
+}
+
+// matchPattern returns true if the path matches the router pattern,
+// params match a single path segment, and catch-all params the rest
+func matchPattern(pattern, path string) bool {
+	for {
+		i := strings.IndexAny(pattern, ":*")
+		if i < 0 {
+			return pattern == path
+		}
+		if !strings.HasPrefix(path, pattern[:i]) {
+			return false
+		}
+		if pattern[i] == '*' {
+			return true
+		}
+		pattern, path = pattern[i:], path[i:]
+
+		// Param value ends at the next slash
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end == 0 {
+			return false
+		}
+		path = path[end:]
+		end = strings.IndexByte(pattern, '/')
+		if end < 0 {
+			end = len(pattern)
+		}
+		pattern = pattern[end:]
+	}
+}
+
+// Handle registers a route handler on the router, and adds it to the Registry
+func (h *Handler) Handle(method, path string, handler http.Handler, o *RouteOptions) {
+	h.Group("").Handle(method, path, handler, o)
+}
+
+// HandlerFunc registers a route handler func, see Handle
+func (h *Handler) HandlerFunc(method, path string, fn http.HandlerFunc, o *RouteOptions) {
+	h.Handle(method, path, fn, o)
+}
+
+// ListRoutes prints the Registry
+func (h *Handler) ListRoutes(w http.ResponseWriter, r *http.Request) {
+	h.JSON(http.StatusOK, w, r, share.RoutesResponse{
+		Routes: h.Registry.Routes(),
+	})
+}
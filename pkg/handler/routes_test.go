@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	h.HandlerFunc("GET", "/", ok, &handler.RouteOptions{
+		Name: "index", Policy: share.PolicyPublic,
+	})
+	h.HandlerFunc("GET", "/users/:id", ok, nil)
+	h.HandlerFunc("GET", "/users", ok, &handler.RouteOptions{
+		Name: "users", Policy: share.PolicyPublic,
+	})
+	h.HandlerFunc("GET", "/users/:id/files/*path", ok, &handler.RouteOptions{
+		Name: "files", Tags: []string{"files"},
+	})
+	h.HandlerFunc("POST", "/users/:id/files", ok, &handler.RouteOptions{
+		Name: "files.upload", SkipMaxBytes: true,
+	})
+	h.Group("/admin").HandlerFunc("GET", "/routes", h.ListRoutes,
+		&handler.RouteOptions{Name: "routes"})
+
+	for _, tc := range []struct {
+		method, path, name string
+		found              bool
+	}{
+		{"GET", "/", "index", true},
+		{"GET", "/users", "users", true},
+		{"GET", "/users/123", "", true},
+		{"GET", "/users/123/files/a/b.txt", "files", true},
+		{"GET", "/users/123/files/", "files", true},
+		{"GET", "/users/", "", false},
+		{"GET", "/users/123/foo", "", false},
+		{"POST", "/users/123", "", false},
+		{"GET", "/admin/routes", "routes", true},
+	} {
+		route, found := h.Registry.Lookup(tc.method, tc.path)
+		require.Equal(t, tc.found, found, tc.path)
+		require.Equal(t, tc.name, route.Name, tc.path)
+	}
+
+	// Default policy
+	route, _ := h.Registry.Lookup("GET", "/users/123")
+	require.Equal(t, share.PolicyToken, route.Policy)
+	req := httptest.NewRequest("GET", "/users", nil)
+	require.True(t, h.Registry.Public(req))
+	req = httptest.NewRequest("GET", "/users/123", nil)
+	require.False(t, h.Registry.Public(req))
+	require.False(t, h.Registry.SkipMaxBytes(req))
+	req = httptest.NewRequest("POST", "/users/123/files", nil)
+	require.True(t, h.Registry.SkipMaxBytes(req))
+	// The router replies with 405
+	req = httptest.NewRequest("DELETE", "/users/123", nil)
+	require.True(t, h.Registry.Public(req))
+	req = httptest.NewRequest("DELETE", "/does/not/exist", nil)
+	require.False(t, h.Registry.Public(req))
+
+	// Route set on the request context
+	req = h.Registry.WithRoute(httptest.NewRequest("GET", "/users", nil))
+	require.True(t, h.Registry.Public(req))
+	req.URL.Path = "/users/123"
+	require.False(t, h.Registry.Public(req), "path changed")
+
+	// Routes are listed in order
+	req = httptest.NewRequest("GET", "/admin/routes", nil)
+	rec := httptest.NewRecorder()
+	h.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	resp := share.RoutesResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	patterns := []string{}
+	for _, route := range resp.Routes {
+		patterns = append(patterns, route.Pattern)
+	}
+	require.Equal(t, []string{
+		"/", "/admin/routes", "/users", "/users/:id", "/users/:id/files",
+		"/users/:id/files/*path",
+	}, patterns)
+	require.Equal(t, []string{"files"}, resp.Routes[5].Tags)
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// SchemaOptions for the Schema wrapper, either schema may be nil
+type SchemaOptions struct {
+	// Request body schema, requests that do not match are rejected.
+	// An empty body is validated as JSON null
+	Request *schema.Schema
+	// Response body schema, only checked in dev mode.
+	// Violations are logged, the response is not modified
+	Response *schema.Schema
+}
+
+// Schema wraps a route handler to validate request and response bodies
+// against JSON Schemas, see pkg/schema
+func (h *Handler) Schema(next http.HandlerFunc, o *SchemaOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.Request != nil {
+			b, err := h.GetBody(r)
+			if err != nil {
+				h.JSON(http.StatusInternalServerError, w, r, err)
+				return
+			}
+
+			var errs []schema.Error
+			if len(b) == 0 {
+				errs = o.Request.Validate(nil)
+			} else {
+				errs, err = o.Request.ValidateJSON(b)
+				if err != nil {
+					h.JSON(http.StatusBadRequest, w, r,
+						errors.Wrap(err, "body must be valid JSON"))
+					return
+				}
+			}
+			if len(errs) > 0 {
+				h.JSON(http.StatusUnprocessableEntity, w, r,
+					schemaValidationError(errs))
+				return
+			}
+
+			// Body was consumed, replace it for the next handler
+			r.Body = ioutil.NopCloser(bytes.NewReader(b))
+			r.ContentLength = int64(len(b))
+		}
+
+		if o.Response == nil || !h.Dev() {
+			next(w, r)
+			return
+		}
+
+		rec := &schemaRecorder{ResponseWriter: w}
+		next(rec, r)
+
+		if !rec.check {
+			return
+		}
+		errs, err := o.Response.ValidateJSON(rec.body.Bytes())
+		if err != nil {
+			errs = []schema.Error{{Path: schema.Root, Message: err.Error()}}
+		}
+		if len(errs) > 0 {
+			// Contract drift must be fixed before clients notice,
+			// make it hard to miss in the dev console
+			log.Ctx(r.Context()).Error().
+				Str("method", r.Method).
+				Str("request_path", r.URL.Path).
+				Interface("schema_errors", errs).
+				Msg("RESPONSE DOES NOT MATCH SCHEMA")
+		}
+	}
+}
+
+// schemaValidationError converts schema errors to a ValidationError
+func schemaValidationError(errs []schema.Error) *ValidationError {
+	verr := &ValidationError{}
+	for _, e := range errs {
+		verr.Errors = append(verr.Errors, share.FieldError{
+			Field:   e.Path,
+			Message: e.Message,
+		})
+	}
+	return verr
+}
+
+// schemaRecorder keeps a copy of successful JSON response bodies.
+// Other responses are not copied, e.g. event streams
+type schemaRecorder struct {
+	http.ResponseWriter
+	wroteHeader bool
+	// check is true if the body must match the schema
+	check bool
+	body  bytes.Buffer
+}
+
+func (rec *schemaRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader && code >= http.StatusOK {
+		rec.wroteHeader = true
+		mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+		rec.check = code < 300 && mediaType == "application/json"
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *schemaRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	if rec.check {
+		rec.body.Write(b)
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush is required for streaming responses
+func (rec *schemaRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap is used by http.ResponseController, e.g. to set deadlines
+func (rec *schemaRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,122 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchema(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	request, err := schema.Parse([]byte(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {"name": {"type": "string", "minLength": 3}}
+	}`))
+	require.NoError(t, err)
+	response, err := schema.Parse([]byte(`{
+		"type": "object",
+		"required": ["message"],
+		"properties": {"message": {"type": "string"}}
+	}`))
+	require.NoError(t, err)
+
+	// Handler echoes the request body
+	wrapped := h.Schema(func(w http.ResponseWriter, r *http.Request) {
+		b, err := h.GetBody(r)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(b)
+	}, &handler.SchemaOptions{Request: request, Response: response})
+
+	logs := &bytes.Buffer{}
+	serve := func(body string) *httptest.ResponseRecorder {
+		logs.Reset()
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		logger := zerolog.New(logs)
+		req = req.WithContext(logger.WithContext(req.Context()))
+		rec := httptest.NewRecorder()
+		wrapped(rec, req)
+		return rec
+	}
+
+	// Request matches, the response does not, logged in dev mode
+	conf.SetDev("true")
+	rec := serve(`{"name": "foo"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"name": "foo"}`, rec.Body.String())
+	require.Contains(t, logs.String(), "RESPONSE DOES NOT MATCH SCHEMA")
+	require.Contains(t, logs.String(), "message")
+
+	// Response matches
+	rec = serve(`{"name": "foo", "message": "bar"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, logs.String(), "RESPONSE DOES NOT MATCH SCHEMA")
+
+	// Response is not checked outside dev mode
+	conf.SetDev("false")
+	rec = serve(`{"name": "foo"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, logs.String(), "RESPONSE DOES NOT MATCH SCHEMA")
+
+	// Request does not match
+	rec = serve(`{"name": "x"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	resp := share.ValidationErrResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Errors, 1)
+	require.Equal(t, "name", resp.Errors[0].Field)
+	rec = serve("")
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = serve("{")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+// TestSchemaStream checks that streaming routes work with response checks
+func TestSchemaStream(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	conf.SetDev("true")
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	response, err := schema.Parse([]byte(`{"type": "object"}`))
+	require.NoError(t, err)
+	flushed := make(chan struct{})
+	srv := httptest.NewServer(h.Schema(
+		func(w http.ResponseWriter, r *http.Request) {
+			// Deadlines are set through the wrapped writer
+			rc := http.NewResponseController(w)
+			require.NoError(t, rc.SetWriteDeadline(time.Now().Add(time.Second)))
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: foo\n\n"))
+			w.(http.Flusher).Flush()
+			<-flushed
+		}, &handler.SchemaOptions{Response: response}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b := make([]byte, 11)
+	_, err = io.ReadFull(resp.Body, b)
+	close(flushed)
+	require.NoError(t, err)
+	require.Equal(t, "data: foo\n\n", string(b))
+}
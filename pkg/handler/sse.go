@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Event is a server-sent event
+type Event struct {
+	// ID is set by Broker.Publish, the broker epoch and a sequence number
+	ID string
+	// Name is the event type, the "message" type is used if empty
+	Name string
+	// Data is written as is if it's a string, otherwise it's encoded as JSON
+	Data interface{}
+
+	seq uint64
+}
+
+type BrokerOptions struct {
+	// ReplaySize is the number of events kept for clients that reconnect
+	// with the Last-Event-ID header, default 100
+	ReplaySize int
+}
+
+// Broker publishes events to subscribed event streams,
+// and keeps a bounded buffer of recent events for replay.
+// Event IDs are prefixed with an epoch that changes when the process
+// restarts, clients that reconnect with an ID from a previous epoch get
+// all buffered events. Clients that missed events that are no longer
+// buffered get a share.EventReset event first
+type Broker struct {
+	mu          sync.Mutex
+	epoch       string
+	replaySize  int
+	replay      []Event
+	lastID      uint64
+	subscribers map[chan Event]struct{}
+}
+
+// NewBroker creates a new event broker
+func NewBroker(o *BrokerOptions) (b *Broker) {
+	b = &Broker{}
+	b.epoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+	b.replaySize = 100
+	if o != nil && o.ReplaySize > 0 {
+		b.replaySize = o.ReplaySize
+	}
+	b.subscribers = make(map[chan Event]struct{})
+	return b
+}
+
+// Publish an event to all subscribers, returns the event with ID set
+func (b *Broker) Publish(name string, data interface{}) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e := Event{
+		ID:   fmt.Sprintf("%s-%d", b.epoch, b.lastID),
+		Name: name,
+		Data: data,
+		seq:  b.lastID,
+	}
+
+	b.replay = append(b.replay, e)
+	if len(b.replay) > b.replaySize {
+		b.replay = b.replay[len(b.replay)-b.replaySize:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// Slow subscriber, the channel is closed to end the stream.
+			// The client reconnects and gets the event from the replay buffer
+			close(ch)
+			delete(b.subscribers, ch)
+		}
+	}
+	return e
+}
+
+// subscribe returns the buffered events published after lastEventID,
+// and a channel for new events. The channel is closed if the subscriber
+// falls behind. Replay starts with a share.EventReset event if events
+// after lastEventID are no longer buffered
+func (b *Broker) subscribe(lastEventID string) (
+	replay []Event, ch chan Event, unsubscribe func(), err error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lastID uint64
+	if lastEventID != "" {
+		epoch, seq, ok := strings.Cut(lastEventID, "-")
+		if !ok {
+			return replay, ch, unsubscribe,
+				errors.Errorf("invalid Last-Event-ID %s", lastEventID)
+		}
+		lastID, err = strconv.ParseUint(seq, 10, 64)
+		if err != nil {
+			return replay, ch, unsubscribe,
+				errors.Errorf("invalid Last-Event-ID %s", lastEventID)
+		}
+		if epoch != b.epoch {
+			// Sequence numbers restart with the process,
+			// events published before the restart are lost
+			lastID = 0
+			replay = append(replay, resetEvent(lastEventID))
+		} else if len(b.replay) > 0 && b.replay[0].seq > lastID+1 {
+			replay = append(replay, resetEvent(lastEventID))
+		}
+	}
+
+	for _, e := range b.replay {
+		if e.seq > lastID {
+			replay = append(replay, e)
+		}
+	}
+
+	ch = make(chan Event, b.replaySize)
+	b.subscribers[ch] = struct{}{}
+	return replay, ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, ch)
+	}, nil
+}
+
+// resetEvent tells the client that events were missed,
+// it has no ID so the client keeps its Last-Event-ID
+func resetEvent(lastEventID string) Event {
+	return Event{Name: share.EventReset, Data: lastEventID}
+}
+
+type SSEOptions struct {
+	// Heartbeat is the interval for comments that keep the connection
+	// open through proxies, default 15s
+	Heartbeat time.Duration
+	// Retry is the reconnection time sent to clients, default 3s
+	Retry time.Duration
+}
+
+// SSE streams events from the broker to the client.
+// Clients that reconnect with the Last-Event-ID header (or last_event_id
+// query param) are sent the events they missed, if still buffered,
+// otherwise a share.EventReset event.
+// The stream ends when the client disconnects, the server shuts down,
+// or the client falls behind by more than ReplaySize events
+func (h *Handler) SSE(w http.ResponseWriter, r *http.Request,
+	b *Broker, o *SSEOptions) {
+
+	ctx := r.Context()
+
+	if o == nil {
+		o = &SSEOptions{}
+	}
+	heartbeat := o.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	retry := o.Retry
+	if retry <= 0 {
+		retry = 3 * time.Second
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		h.JSON(http.StatusInternalServerError, w, r,
+			errors.Errorf("streaming not supported"))
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	replay, events, unsubscribe, err := b.subscribe(lastEventID)
+	if err != nil {
+		h.JSON(http.StatusBadRequest, w, r, err)
+		return
+	}
+	defer unsubscribe()
+
+	// Write headers
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering by proxies, e.g. nginx
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// The server WriteTimeout applies to the whole response,
+	// extend the deadline before each write instead
+	rc := http.NewResponseController(w)
+	write := func(p []byte) error {
+		// Ignore the error, not all response writers support deadlines
+		_ = rc.SetWriteDeadline(time.Now().Add(2 * heartbeat))
+		_, err := w.Write(p)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	count := 0
+	err = write([]byte(fmt.Sprintf("retry: %d\n\n", retry.Milliseconds())))
+	for _, e := range replay {
+		if err != nil {
+			break
+		}
+		err = write(encodeEvent(e))
+		count++
+	}
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	msg := "client disconnected"
+	slow := false
+loop:
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			break loop
+
+		case <-h.Done():
+			msg = "server shutting down"
+			break loop
+
+		case <-ticker.C:
+			err = write([]byte(": heartbeat\n\n"))
+
+		case e, ok := <-events:
+			if !ok {
+				// Closed by the broker
+				msg, slow = "client too slow, reconnect to replay", true
+				break loop
+			}
+			err = write(encodeEvent(e))
+			count++
+		}
+	}
+
+	logEvent := log.Ctx(ctx).Info()
+	if slow {
+		logEvent = log.Ctx(ctx).Warn()
+	}
+	if err != nil {
+		logEvent = log.Ctx(ctx).Error().Stack().Err(err)
+		msg = err.Error()
+	}
+	logEvent.Int("events", count)
+	logResponse(r, logEvent, http.StatusOK, msg)
+}
+
+// encodeEvent in the text/event-stream format
+func encodeEvent(e Event) []byte {
+	var data string
+	switch v := e.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			data = err.Error()
+		} else {
+			data = string(b)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	if e.ID != "" {
+		buf.WriteString(fmt.Sprintf("id: %s\n", e.ID))
+	}
+	if e.Name != "" {
+		buf.WriteString(fmt.Sprintf("event: %s\n", e.Name))
+	}
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString(fmt.Sprintf("data: %s\n", line))
+	}
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,154 @@
+package handler_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSE(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	b := handler.NewBroker(&handler.BrokerOptions{ReplaySize: 2})
+	ids := []string{}
+	for _, data := range []string{"a", "b", "c"} {
+		ids = append(ids, b.Publish("foo", data).ID)
+	}
+	epoch := strings.TrimSuffix(ids[0], "-1")
+	require.NotEqual(t, ids[0], epoch)
+
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			h.SSE(w, r, b, &handler.SSEOptions{Heartbeat: time.Hour})
+		}))
+	defer srv.Close()
+
+	subscribe := func(lastEventID string) (
+		lines chan string, next func(n int) string, stop func()) {
+
+		req, err := http.NewRequest("GET", srv.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Last-Event-ID", lastEventID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		lines = make(chan string)
+		go func() {
+			defer close(lines)
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				lines <- scanner.Text()
+			}
+		}()
+		next = func(n int) string {
+			s := []string{}
+			for i := 0; i < n; i++ {
+				select {
+				case line := <-lines:
+					s = append(s, line)
+				case <-time.After(5 * time.Second):
+					t.Fatal("timeout")
+				}
+			}
+			return strings.Join(s, "\n")
+		}
+		return lines, next, func() {
+			_ = resp.Body.Close()
+		}
+	}
+
+	// Event ID from a previous process, all buffered events are replayed
+	// after a reset event
+	_, next, closeStream := subscribe("abc-3")
+	require.Equal(t, "retry: 3000\n", next(2))
+	require.Equal(t, "event: reset\ndata: abc-3\n", next(3))
+	require.Equal(t, "id: "+epoch+"-2\nevent: foo\ndata: b\n", next(4))
+	require.Equal(t, "id: "+epoch+"-3\nevent: foo\ndata: c\n", next(4))
+	closeStream()
+
+	// Event 1 is no longer buffered
+	_, next, closeStream = subscribe(epoch + "-0")
+	require.Equal(t, "retry: 3000\n", next(2))
+	require.Equal(t, "event: reset\ndata: "+epoch+"-0\n", next(3))
+	require.Equal(t, "id: "+epoch+"-2\nevent: foo\ndata: b\n", next(4))
+	closeStream()
+
+	// Resume after event 2, event 3 is still buffered
+	lines, next, closeStream := subscribe(ids[1])
+	defer closeStream()
+	require.Equal(t, "retry: 3000\n", next(2))
+	require.Equal(t, "id: "+epoch+"-3\nevent: foo\ndata: c\n", next(4))
+
+	// Live event, data is encoded as JSON
+	b.Publish("", map[string]int{"bar": 1})
+	require.Equal(t, "id: "+epoch+"-4\ndata: {\"bar\":1}\n", next(3))
+
+	// Invalid ID
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "4")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// Stream ends on shutdown
+	h.Shutdown()
+	select {
+	case _, ok := <-lines:
+		require.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed on shutdown")
+	}
+}
+
+func TestSSESlowClient(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	b := handler.NewBroker(&handler.BrokerOptions{ReplaySize: 2})
+	subscribed := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			close(subscribed)
+			h.SSE(w, r, b, &handler.SSEOptions{Heartbeat: time.Hour})
+		}))
+	defer srv.Close()
+
+	// Events are not read until the buffer overflows
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	<-subscribed
+	time.Sleep(100 * time.Millisecond)
+	for i := 0; i < 100; i++ {
+		b.Publish("foo", strings.Repeat("x", 1024))
+	}
+
+	// Stream ends, so the client reconnects to replay
+	done := make(chan error)
+	go func() {
+		_, err := io.Copy(io.Discard, resp.Body)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow client not closed")
+	}
+}
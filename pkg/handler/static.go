@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+)
+
+// Cache-Control values for static files
+const (
+	// CacheImmutable is used for fingerprinted URLs, see Static.URL
+	CacheImmutable = "public, max-age=31536000, immutable"
+	// CacheRevalidate is the default, clients must check the ETag
+	CacheRevalidate = "no-cache"
+)
+
+// CacheRule sets Cache-Control for files matching Glob.
+// Globs use path.Match syntax, globs without a slash match the file name
+type CacheRule struct {
+	Glob         string
+	CacheControl string
+}
+
+type StaticOptions struct {
+	// FS to serve files from
+	FS fs.FS
+	// CacheControl rules, the first matching rule is used.
+	// Default is CacheRevalidate
+	CacheControl []CacheRule
+	// Fallback is served for paths without a file extension that do not
+	// match a file, e.g. "index.html" for single page apps
+	Fallback string
+	// Exclude files matching these globs, e.g. templates
+	Exclude []string
+	// Route describes the routes in the Registry
+	Route *RouteOptions
+}
+
+// Static serves files with cache headers.
+// Directory listings are disabled, a dir is served only if it has index.html.
+// If the client accepts it, a ".br" or ".gz" sibling of the file is served
+type Static struct {
+	prefix   string
+	fsys     fs.FS
+	rules    []CacheRule
+	fallback string
+	exclude  []string
+	route    *RouteOptions
+
+	mu     sync.Mutex
+	hashes map[string]fileHash
+}
+
+// fileHash is cached until the file changes
+type fileHash struct {
+	modTime time.Time
+	size    int64
+	sum     string
+}
+
+// fingerprintLen is the number of hex chars of the hash used in URLs
+const fingerprintLen = 10
+
+// fingerprintRe matches fingerprinted file names, e.g. main.0123456789.css
+var fingerprintRe = regexp.MustCompile(
+	`^(.+)\.([0-9a-f]{` + strconv.Itoa(fingerprintLen) + `})(\.[^./]+)$`)
+
+// NewStatic creates a static file server for URLs starting with prefix,
+// e.g. "/www", see ServeStatic
+func NewStatic(prefix string, o *StaticOptions) *Static {
+	return &Static{
+		prefix:   strings.TrimSuffix(prefix, "/"),
+		fsys:     o.FS,
+		rules:    o.CacheControl,
+		fallback: o.Fallback,
+		exclude:  o.Exclude,
+		route:    o.Route,
+		hashes:   make(map[string]fileHash),
+	}
+}
+
+// URL returns the fingerprinted URL for the named file,
+// e.g. "css/main.css" becomes "/www/css/main.0123456789.css".
+// The URL changes when the file changes, so it can be cached forever
+func (s *Static) URL(name string) string {
+	sum, err := s.hash(name)
+	if err != nil {
+		// Not fingerprinted
+		return s.prefix + "/" + name
+	}
+	ext := path.Ext(name)
+	return fmt.Sprintf("%s/%s.%s%s",
+		s.prefix, strings.TrimSuffix(name, ext), sum[:fingerprintLen], ext)
+}
+
+// hash returns the hex encoded SHA-256 of the named file
+func (s *Static) hash(name string) (sum string, err error) {
+	fi, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return sum, errors.WithStack(err)
+	}
+
+	s.mu.Lock()
+	cached, ok := s.hashes[name]
+	s.mu.Unlock()
+	if ok && cached.modTime.Equal(fi.ModTime()) && cached.size == fi.Size() {
+		return cached.sum, nil
+	}
+
+	b, err := fs.ReadFile(s.fsys, name)
+	if err != nil {
+		return sum, errors.WithStack(err)
+	}
+	h := sha256.Sum256(b)
+	sum = hex.EncodeToString(h[:])
+
+	s.mu.Lock()
+	s.hashes[name] = fileHash{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		sum:     sum,
+	}
+	s.mu.Unlock()
+	return sum, nil
+}
+
+// match returns true if name matches the glob
+func match(glob, name string) bool {
+	if !strings.Contains(glob, "/") {
+		name = path.Base(name)
+	}
+	ok, _ := path.Match(glob, name)
+	return ok
+}
+
+func (s *Static) excluded(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." {
+			// Hidden files
+			return true
+		}
+	}
+	for _, glob := range s.exclude {
+		if match(glob, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Static) cacheControl(name string) string {
+	for _, rule := range s.rules {
+		if match(rule.Glob, name) {
+			return rule.CacheControl
+		}
+	}
+	return CacheRevalidate
+}
+
+// resolve the request path to a file name and Cache-Control value,
+// returns false if there is no file to serve
+func (s *Static) resolve(p string) (name, cacheControl string, ok bool) {
+	name = path.Clean(strings.TrimPrefix(p, "/"))
+	if name == "" || name == "/" {
+		name = "."
+	}
+	if !fs.ValidPath(name) || s.excluded(name) {
+		return "", "", false
+	}
+
+	fi, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		if m := fingerprintRe.FindStringSubmatch(name); m != nil {
+			original := m[1] + m[3]
+			sum, err := s.hash(original)
+			if err == nil && !s.excluded(original) {
+				if sum[:fingerprintLen] == m[2] {
+					return original, CacheImmutable, true
+				}
+				// Outdated link, serve the current file
+				return original, CacheRevalidate, true
+			}
+		}
+		if s.fallback != "" && path.Ext(name) == "" {
+			return s.fallback, CacheRevalidate, true
+		}
+		return "", "", false
+	}
+
+	if fi.IsDir() {
+		index := path.Join(name, "index.html")
+		_, err = fs.Stat(s.fsys, index)
+		if err == nil {
+			return index, s.cacheControl(index), true
+		}
+		if s.fallback != "" {
+			return s.fallback, CacheRevalidate, true
+		}
+		return "", "", false
+	}
+
+	return name, s.cacheControl(name), true
+}
+
+// EncodingQ returns the quality value of the content coding
+// in the Accept-Encoding header, zero if not acceptable
+func EncodingQ(header, coding string) float64 {
+	q := 0.0
+	found := false
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name != coding && name != "*" {
+			continue
+		}
+		v := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				parsed, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					v = parsed
+				}
+			}
+		}
+		if name == coding {
+			// Explicit coding takes precedence over the wildcard
+			return v
+		}
+		if !found {
+			q = v
+			found = true
+		}
+	}
+	return q
+}
+
+// precompressed siblings by content coding, in order of preference
+var precompressed = []struct {
+	coding string
+	ext    string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// variant returns the precompressed sibling of name to serve,
+// and sets the Vary header if there are any siblings
+func (s *Static) variant(w http.ResponseWriter, r *http.Request,
+	name string) (served, coding string) {
+
+	if mime.TypeByExtension(path.Ext(name)) == "" {
+		// Content type can not be detected from compressed content
+		return name, ""
+	}
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	best := 0.0
+	served = name
+	vary := false
+	for _, pc := range precompressed {
+		_, err := fs.Stat(s.fsys, name+pc.ext)
+		if err != nil {
+			continue
+		}
+		vary = true
+		q := EncodingQ(acceptEncoding, pc.coding)
+		if q > best {
+			best = q
+			served = name + pc.ext
+			coding = pc.coding
+		}
+	}
+	if vary {
+		Vary(w.Header(), "Accept-Encoding")
+	}
+	return served, coding
+}
+
+// ServeStatic registers GET and HEAD routes for the static file server
+func (h *Handler) ServeStatic(s *Static) {
+	handle := func(w http.ResponseWriter, r *http.Request) {
+		p := httprouter.ParamsFromContext(r.Context())
+		name, cacheControl, ok := s.resolve(p.ByName("filepath"))
+		if !ok {
+			h.JSON(http.StatusNotFound, w, r, share.Response{
+				Message: fmt.Sprintf("path not found %v", r.URL.Path),
+			})
+			return
+		}
+
+		served, coding := s.variant(w, r, name)
+		f, err := s.fsys.Open(served)
+		if err != nil {
+			h.JSON(http.StatusInternalServerError, w, r, errors.WithStack(err))
+			return
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			h.JSON(http.StatusInternalServerError, w, r, errors.WithStack(err))
+			return
+		}
+		content, ok := f.(io.ReadSeeker)
+		if !ok {
+			b, err := io.ReadAll(f)
+			if err != nil {
+				h.JSON(http.StatusInternalServerError, w, r,
+					errors.WithStack(err))
+				return
+			}
+			content = bytes.NewReader(b)
+		}
+		sum, err := s.hash(served)
+		if err != nil {
+			h.JSON(http.StatusInternalServerError, w, r, err)
+			return
+		}
+
+		w.Header().Set("Cache-Control", cacheControl)
+		// Conditional and range requests are handled by ServeContent
+		w.Header().Set("ETag", "\""+sum[:32]+"\"")
+		if coding != "" {
+			w.Header().Set("Content-Encoding", coding)
+			w.Header().Set("Content-Type",
+				mime.TypeByExtension(path.Ext(name)))
+		}
+		http.ServeContent(w, r, name, fi.ModTime(), content)
+	}
+
+	h.HandlerFunc("GET", s.prefix+"/*filepath", handle, s.route)
+	h.HandlerFunc("HEAD", s.prefix+"/*filepath", handle, s.route)
+}
@@ -0,0 +1,111 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatic(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	fsys := fstest.MapFS{
+		"index.html":          {Data: []byte("<p>app</p>")},
+		"css/main.css":        {Data: []byte("body {}")},
+		"css/main.css.br":     {Data: []byte("br")},
+		"css/main.css.gz":     {Data: []byte("gz")},
+		"img/logo.png":        {Data: []byte("png")},
+		"templates/page.html": {Data: []byte("{{.}}")},
+		".env":                {Data: []byte("secret")},
+	}
+	s := handler.NewStatic("/www", &handler.StaticOptions{
+		FS: fsys,
+		CacheControl: []handler.CacheRule{
+			{Glob: "img/*", CacheControl: "public, max-age=60"},
+		},
+		Exclude: []string{"templates/*"},
+	})
+	h.ServeStatic(s)
+
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		h.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Cache rules
+	rec := get("/www/img/logo.png", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "public, max-age=60", rec.Header().Get("Cache-Control"))
+	rec = get("/www/index.html", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, handler.CacheRevalidate, rec.Header().Get("Cache-Control"))
+
+	// ETag
+	req := httptest.NewRequest("GET", "/www/index.html", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	h.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotModified, rec.Code)
+
+	// Fingerprinted URLs
+	u := s.URL("css/main.css")
+	require.Regexp(t, `^/www/css/main\.[0-9a-f]{10}\.css$`, u)
+	rec = get(u, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, handler.CacheImmutable, rec.Header().Get("Cache-Control"))
+	require.Equal(t, "body {}", rec.Body.String())
+	rec = get("/www/css/main.0123456789.css", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, handler.CacheRevalidate, rec.Header().Get("Cache-Control"))
+
+	// Precompressed siblings
+	rec = get("/www/css/main.css", "gzip, br")
+	require.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	require.Equal(t, "br", rec.Body.String())
+	require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	require.Contains(t, rec.Header().Get("Content-Type"), "text/css")
+	rec = get("/www/css/main.css", "gzip, br;q=0.5")
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	require.Equal(t, "gz", rec.Body.String())
+	rec = get("/www/css/main.css", "*;q=0")
+	require.Empty(t, rec.Header().Get("Content-Encoding"))
+	require.Equal(t, "body {}", rec.Body.String())
+
+	// Directory listings, excluded and hidden files
+	for _, path := range []string{
+		"/www/css/", "/www/templates/page.html", "/www/.env",
+		"/www/missing", "/www/../handler.go"} {
+		rec = get(path, "")
+		require.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+	rec = get("/www/", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "<p>app</p>", rec.Body.String())
+
+	// Single page app fallback
+	h = handler.NewHandler(conf)
+	h.ServeStatic(handler.NewStatic("/app", &handler.StaticOptions{
+		FS:       fsys,
+		Fallback: "index.html",
+	}))
+	for _, path := range []string{"/app/users/1", "/app/css/"} {
+		rec = get(path, "")
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		require.Equal(t, "<p>app</p>", rec.Body.String(), path)
+	}
+	rec = get("/app/missing.js", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
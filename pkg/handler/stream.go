@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// Stream formats
+const (
+	// StreamNDJSON writes one JSON document per line
+	StreamNDJSON = "ndjson"
+	// StreamJSONArray writes the items as a single JSON array
+	StreamJSONArray = "array"
+)
+
+// Iterator yields items for streaming responses,
+// Next must return io.EOF when there are no more items
+type Iterator interface {
+	Next(ctx context.Context) (item interface{}, err error)
+}
+
+// IteratorFunc adapts a func to the Iterator interface
+type IteratorFunc func(ctx context.Context) (item interface{}, err error)
+
+func (fn IteratorFunc) Next(ctx context.Context) (interface{}, error) {
+	return fn(ctx)
+}
+
+// ChanIterator yields items received on ch until it is closed
+func ChanIterator(ch <-chan interface{}) Iterator {
+	return IteratorFunc(func(ctx context.Context) (interface{}, error) {
+		select {
+		case item, ok := <-ch:
+			if !ok {
+				return nil, io.EOF
+			}
+			return item, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+}
+
+type StreamOptions struct {
+	// Format is StreamNDJSON (default) or StreamJSONArray
+	Format string
+	// FlushItems is the max number of items to buffer, default 100
+	FlushItems int
+	// FlushInterval is the max time to buffer items, default 1s
+	FlushInterval time.Duration
+}
+
+// streamItem is passed from the iterator goroutine to the writer
+type streamItem struct {
+	item interface{}
+	err  error
+}
+
+// Stream encodes items from the iterator without buffering the whole
+// response in memory. Items are flushed periodically, and iteration stops
+// if the client disconnects. The status code can't be changed once the
+// first item is written, iterator errors are logged and end the stream
+func (h *Handler) Stream(code int, w http.ResponseWriter, r *http.Request,
+	it Iterator, o *StreamOptions) {
+
+	if o == nil {
+		o = &StreamOptions{}
+	}
+	format := o.Format
+	if format == "" {
+		format = StreamNDJSON
+	}
+	flushItems := o.FlushItems
+	if flushItems <= 0 {
+		flushItems = 100
+	}
+	flushInterval := o.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+
+	// Cancelled when the client disconnects, or the stream ends
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// Next may block, e.g. waiting on a channel,
+	// read items in a goroutine so buffered items are flushed on time
+	items := make(chan streamItem)
+	go func() {
+		defer close(items)
+		for {
+			item, err := it.Next(ctx)
+			select {
+			case items <- streamItem{item: item, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Write headers
+	contentType := "application/x-ndjson; charset=UTF-8"
+	if format == StreamJSONArray {
+		contentType = "application/json; charset=UTF-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(code)
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	count := 0
+	buffered := 0
+	var streamErr error
+	if format == StreamJSONArray {
+		_, streamErr = w.Write([]byte("["))
+	}
+
+loop:
+	for streamErr == nil {
+		select {
+		case <-ctx.Done():
+			streamErr = errors.Wrap(ctx.Err(), "client disconnected")
+
+		case <-ticker.C:
+			if buffered > 0 {
+				flush()
+				buffered = 0
+			}
+
+		case next, ok := <-items:
+			if !ok {
+				break loop
+			}
+			if next.err == io.EOF {
+				break loop
+			}
+			if next.err != nil {
+				streamErr = errors.WithStack(next.err)
+				break
+			}
+
+			b, err := json.Marshal(next.item)
+			if err != nil {
+				streamErr = errors.WithStack(err)
+				break
+			}
+			if format == StreamJSONArray {
+				if count > 0 {
+					b = append([]byte(","), b...)
+				}
+			} else {
+				b = append(b, '\n')
+			}
+			_, err = w.Write(b)
+			if err != nil {
+				streamErr = errors.WithStack(err)
+				break
+			}
+			count++
+			buffered++
+			if buffered >= flushItems {
+				flush()
+				buffered = 0
+			}
+		}
+	}
+
+	if format == StreamJSONArray && streamErr == nil {
+		_, streamErr = w.Write([]byte("]"))
+		streamErr = errors.WithStack(streamErr)
+	}
+	flush()
+
+	// Log the same way as JSON, with the item count
+	logEvent := log.Ctx(r.Context()).Info()
+	msg := http.StatusText(code)
+	if streamErr != nil {
+		logEvent = log.Ctx(r.Context()).Error().Stack().Err(streamErr)
+		msg = streamErr.Error()
+	}
+	logEvent.Int("items", count)
+	logResponse(r, logEvent, code, msg)
+}
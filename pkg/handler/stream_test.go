@@ -0,0 +1,71 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	items := func(n int) handler.Iterator {
+		ch := make(chan interface{})
+		go func() {
+			defer close(ch)
+			for i := 0; i < n; i++ {
+				ch <- share.Response{Message: "foo"}
+			}
+		}()
+		return handler.ChanIterator(ch)
+	}
+
+	req, err := http.NewRequest("GET", "/", nil)
+	require.NoError(t, err)
+
+	// NDJSON
+	rec := httptest.NewRecorder()
+	h.Stream(http.StatusOK, rec, req, items(3),
+		&handler.StreamOptions{FlushItems: 2})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.True(t, rec.Flushed)
+	require.Equal(t, "application/x-ndjson; charset=UTF-8",
+		rec.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, `{"message":"foo"}`, lines[2])
+
+	// JSON array
+	rec = httptest.NewRecorder()
+	h.Stream(http.StatusOK, rec, req, items(3),
+		&handler.StreamOptions{Format: handler.StreamJSONArray})
+	var a []share.Response
+	err = json.Unmarshal(rec.Body.Bytes(), &a)
+	require.NoError(t, err)
+	require.Len(t, a, 3)
+
+	// Empty JSON array
+	rec = httptest.NewRecorder()
+	h.Stream(http.StatusOK, rec, req, items(0),
+		&handler.StreamOptions{Format: handler.StreamJSONArray})
+	require.Equal(t, "[]", rec.Body.String())
+
+	// Client disconnects
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	rec = httptest.NewRecorder()
+	h.Stream(http.StatusOK, rec, req.WithContext(ctx),
+		handler.ChanIterator(make(chan interface{})), nil)
+	require.Empty(t, rec.Body.String())
+}
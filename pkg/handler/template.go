@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+)
+
+// Template dirs, relative to the root of RendererOptions.FS.
+// Template names must be unique across dirs
+const (
+	// TemplateLayouts define the page structure, e.g. {{define "base"}}
+	TemplateLayouts = "layouts"
+	// TemplatePartials are included by layouts and pages
+	TemplatePartials = "partials"
+	// TemplatePages are rendered by name, e.g. "index.html"
+	TemplatePages = "pages"
+)
+
+type RendererOptions struct {
+	// FS containing the template dirs, e.g. www
+	FS fs.FS
+	// Reload parses templates again if a file changed, use in dev only
+	Reload bool
+	// Funcs available to all templates
+	Funcs template.FuncMap
+}
+
+// Renderer executes html templates.
+// Each page is parsed with all layouts and partials
+type Renderer struct {
+	fsys   fs.FS
+	reload bool
+	funcs  template.FuncMap
+
+	mu    sync.RWMutex
+	pages map[string]*template.Template
+	// modTime of the latest file, and number of files parsed,
+	// used to detect changes if reload is enabled
+	modTime time.Time
+	files   int
+}
+
+// NewRenderer parses the templates in o.FS
+func NewRenderer(o *RendererOptions) (rr *Renderer, err error) {
+	rr = &Renderer{
+		fsys:   o.FS,
+		reload: o.Reload,
+		funcs:  o.Funcs,
+	}
+	err = rr.parse()
+	if err != nil {
+		return nil, err
+	}
+	return rr, nil
+}
+
+// glob lists template files in each dir
+func (rr *Renderer) glob(dirs ...string) (files []string, err error) {
+	for _, dir := range dirs {
+		matches, err := fs.Glob(rr.fsys, path.Join(dir, "*.html"))
+		if err != nil {
+			return files, errors.WithStack(err)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// changed returns the latest modification time and number of files
+func (rr *Renderer) changed() (modTime time.Time, files int, err error) {
+	paths, err := rr.glob(TemplateLayouts, TemplatePartials, TemplatePages)
+	if err != nil {
+		return modTime, files, err
+	}
+	for _, name := range paths {
+		fi, err := fs.Stat(rr.fsys, name)
+		if err != nil {
+			return modTime, files, errors.WithStack(err)
+		}
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+	}
+	return modTime, len(paths), nil
+}
+
+func (rr *Renderer) parse() error {
+	modTime, files, err := rr.changed()
+	if err != nil {
+		return err
+	}
+
+	base := template.New("").Funcs(rr.funcs)
+	shared, err := rr.glob(TemplateLayouts, TemplatePartials)
+	if err != nil {
+		return err
+	}
+	if len(shared) > 0 {
+		_, err = base.ParseFS(rr.fsys, shared...)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	pagePaths, err := rr.glob(TemplatePages)
+	if err != nil {
+		return err
+	}
+	pages := make(map[string]*template.Template)
+	for _, name := range pagePaths {
+		t, err := base.Clone()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = t.ParseFS(rr.fsys, name)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		pages[path.Base(name)] = t
+	}
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.pages = pages
+	rr.modTime = modTime
+	rr.files = files
+	return nil
+}
+
+// Render writes the named page to w,
+// templates are parsed again first if reload is enabled and a file changed
+func (rr *Renderer) Render(w io.Writer, page string, data interface{}) error {
+	if rr.reload {
+		modTime, files, err := rr.changed()
+		if err != nil {
+			return err
+		}
+		rr.mu.RLock()
+		changed := !modTime.Equal(rr.modTime) || files != rr.files
+		rr.mu.RUnlock()
+		if changed {
+			err = rr.parse()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	rr.mu.RLock()
+	t, ok := rr.pages[page]
+	rr.mu.RUnlock()
+	if !ok {
+		return errors.Errorf("page not found %s", page)
+	}
+	return errors.WithStack(t.ExecuteTemplate(w, page, data))
+}
+
+// TemplateData is passed to templates by HTML
+type TemplateData struct {
+	RequestID string
+	// Principal is nil if the request is not authenticated,
+	// public routes have it if the request has a valid token
+	Principal *share.Principal
+	Config    *config.Config
+	// Data from the route handler
+	Data interface{}
+}
+
+// HTML renders the page with h.Renderer, and writes the response.
+// Render errors are written with JSON, the response is buffered so
+// partially rendered pages are not sent
+func (h *Handler) HTML(code int, w http.ResponseWriter, r *http.Request,
+	page string, data interface{}) {
+
+	if h.Renderer == nil {
+		h.JSON(http.StatusInternalServerError, w, r,
+			errors.Errorf("renderer not set"))
+		return
+	}
+
+	ctx := r.Context()
+	td := TemplateData{
+		Config: h.Config,
+		Data:   data,
+	}
+	requestID, ok := ctx.Value(share.HeaderXRequestID).(string)
+	if ok {
+		td.RequestID = requestID
+	}
+	principal, ok := ctx.Value(share.ContextPrincipal).(share.Principal)
+	if ok {
+		td.Principal = &principal
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+	err := h.Renderer.Render(&buf.Buffer, page, td)
+	if err != nil {
+		h.JSON(http.StatusInternalServerError, w, r, err)
+		return
+	}
+	h.Write(code, "text/html; charset=UTF-8", w, r, buf.Bytes())
+}
@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplate(t *testing.T, dir, name, text string, modTime time.Time) {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(text), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestHTML(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour)
+	writeTemplate(t, dir, "layouts/base.html",
+		`{{define "base"}}<main>{{block "content" .}}{{end}}</main>`+
+			`{{template "footer" .}}{{end}}`, modTime)
+	writeTemplate(t, dir, "partials/footer.html",
+		`{{define "footer"}}<footer>{{.RequestID}}`+
+			`{{with .Principal}} {{.ID}}{{end}}</footer>{{end}}`, modTime)
+	writeTemplate(t, dir, "pages/index.html",
+		`{{define "content"}}{{.Data}}{{end}}{{template "base" .}}`, modTime)
+
+	// Dev mode
+	h.Renderer, err = handler.NewRenderer(&handler.RendererOptions{
+		FS:     os.DirFS(dir),
+		Reload: true,
+	})
+	require.NoError(t, err)
+
+	// Request ID and principal are injected, data is escaped
+	req := httptest.NewRequest("GET", "/", nil)
+	ctx := context.WithValue(req.Context(), share.HeaderXRequestID, "abc")
+	ctx = context.WithValue(ctx, share.ContextPrincipal,
+		share.Principal{ID: "demo"})
+	req = req.WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.HTML(http.StatusOK, rec, req, "index.html", "<b>foo</b>")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/html; charset=UTF-8",
+		rec.Header().Get("Content-Type"))
+	require.Equal(t,
+		"<main>&lt;b&gt;foo&lt;/b&gt;</main><footer>abc demo</footer>",
+		rec.Body.String())
+
+	// Templates are parsed again on change
+	writeTemplate(t, dir, "partials/footer.html",
+		`{{define "footer"}}<footer>changed</footer>{{end}}`, time.Now())
+	rec = httptest.NewRecorder()
+	h.HTML(http.StatusOK, rec, req, "index.html", "foo")
+	require.Equal(t, "<main>foo</main><footer>changed</footer>",
+		rec.Body.String())
+
+	// Render errors are written as JSON
+	rec = httptest.NewRecorder()
+	h.HTML(http.StatusOK, rec, req, "missing.html", nil)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	require.Equal(t, "application/json; charset=UTF-8",
+		rec.Header().Get("Content-Type"))
+
+	// Templates are cached in prod
+	h.Renderer, err = handler.NewRenderer(&handler.RendererOptions{
+		FS: os.DirFS(dir),
+	})
+	require.NoError(t, err)
+	writeTemplate(t, dir, "pages/index.html",
+		`{{define "content"}}bar{{end}}{{template "base" .}}`,
+		time.Now().Add(time.Hour))
+	rec = httptest.NewRecorder()
+	h.HTML(http.StatusOK, rec, req, "index.html", "foo")
+	require.Equal(t, "<main>foo</main><footer>changed</footer>",
+		rec.Body.String())
+}
@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/units"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// sniffLen is the number of bytes used to detect the content type,
+// see http.DetectContentType
+const sniffLen = 512
+
+type UploadOptions struct {
+	// MaxFileBytes limits the size of each file, default MaxTotalBytes
+	MaxFileBytes int64
+	// MaxTotalBytes limits the size of the request body,
+	// default APP_MAX_PAYLOAD_MB
+	MaxTotalBytes int64
+	// MaxFiles per request, default 10
+	MaxFiles int
+	// ContentTypes of files that are accepted, all types if empty.
+	// The type is detected from the file content, the type sent by the
+	// client is ignored. Types ending with "/*" match all subtypes
+	ContentTypes []string
+}
+
+// UploadFile is spooled to a temp file
+type UploadFile struct {
+	share.UploadedFile
+	// File is open for reading, it is closed and removed after
+	// the UploadFunc returns
+	File *os.File
+}
+
+// Upload contains the files and values of a multipart form
+type Upload struct {
+	Files  []*UploadFile
+	Values url.Values
+	dir    string
+}
+
+// UploadFunc handles the upload,
+// move files that must be kept before returning
+type UploadFunc func(w http.ResponseWriter, r *http.Request, u *Upload)
+
+// uploadError sets the response status code
+type uploadError struct {
+	code int
+	err  error
+}
+
+func (e *uploadError) Error() string {
+	return e.err.Error()
+}
+
+// Cause is used by errors.Cause
+func (e *uploadError) Cause() error {
+	return e.err
+}
+
+func newUploadError(code int, format string, args ...interface{}) error {
+	return &uploadError{code: code, err: errors.Errorf(format, args...)}
+}
+
+// Upload wraps a route handler to read multipart/form-data requests.
+// Files are streamed to a temp dir under APP_DIR while they are hashed,
+// the whole request is never buffered in memory.
+// Temp files are removed when the request ends
+func (h *Handler) Upload(next UploadFunc, o *UploadOptions) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := h.readUpload(w, r, o)
+		if u != nil {
+			defer u.cleanup(r)
+		}
+		if err != nil {
+			code := http.StatusInternalServerError
+			if uerr, ok := err.(*uploadError); ok {
+				code = uerr.code
+			}
+			h.JSON(code, w, r, err)
+			return
+		}
+		next(w, r, u)
+	}
+}
+
+func (h *Handler) readUpload(w http.ResponseWriter, r *http.Request,
+	o *UploadOptions) (u *Upload, err error) {
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return nil, newUploadError(http.StatusUnsupportedMediaType,
+			"content type must be multipart/form-data")
+	}
+
+	maxTotal := o.MaxTotalBytes
+	if maxTotal <= 0 {
+		maxPayload, err := h.Config.FnMaxPayloadMb().Int64()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		maxTotal = maxPayload * int64(units.MiB)
+	}
+	maxFile := o.MaxFileBytes
+	if maxFile <= 0 || maxFile > maxTotal {
+		maxFile = maxTotal
+	}
+	maxFiles := o.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = 10
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxTotal)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, newUploadError(http.StatusBadRequest, "%s", err.Error())
+	}
+
+	tmp := filepath.Join(h.Config.Dir(), "tmp")
+	err = os.MkdirAll(tmp, 0700)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dir, err := os.MkdirTemp(tmp, "upload-")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	u = &Upload{
+		Values: url.Values{},
+		dir:    dir,
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return u, nil
+		}
+		if err != nil {
+			return u, uploadReadError(err)
+		}
+
+		if part.FileName() == "" {
+			// Form values count towards the total
+			b, err := io.ReadAll(part)
+			if err != nil {
+				return u, uploadReadError(err)
+			}
+			u.Values.Add(part.FormName(), string(b))
+			continue
+		}
+
+		if len(u.Files) == maxFiles {
+			return u, newUploadError(http.StatusRequestEntityTooLarge,
+				"too many files, max %d", maxFiles)
+		}
+		f, err := h.spool(part, dir, maxFile, o.ContentTypes)
+		if f != nil {
+			u.Files = append(u.Files, f)
+		}
+		if err != nil {
+			return u, err
+		}
+	}
+}
+
+// uploadReadError is returned for errors reading the request body,
+// h.JSON responds with 413 if the total limit was exceeded
+func uploadReadError(err error) error {
+	return &uploadError{code: http.StatusBadRequest, err: errors.WithStack(err)}
+}
+
+// spool the part to a temp file in dir
+func (h *Handler) spool(part *multipart.Part, dir string, maxFile int64,
+	contentTypes []string) (f *UploadFile, err error) {
+
+	// Detect the content type before writing to disk
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, uploadReadError(err)
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if len(contentTypes) > 0 && !MatchMediaType(contentTypes, mediaType) {
+		return nil, newUploadError(http.StatusUnsupportedMediaType,
+			"file %s type %s is not allowed", part.FileName(), mediaType)
+	}
+
+	file, err := os.CreateTemp(dir, "file-")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	f = &UploadFile{File: file}
+	f.Field = part.FormName()
+	f.Filename = filepath.Base(part.FileName())
+	f.ContentType = contentType
+
+	hash := sha256.New()
+	dst := io.MultiWriter(file, hash)
+	src := io.MultiReader(bytes.NewReader(head),
+		// Read one more byte to detect files that exceed the limit
+		io.LimitReader(part, maxFile-int64(n)+1))
+	size, err := io.Copy(dst, src)
+	if err != nil {
+		return f, uploadReadError(err)
+	}
+	if size > maxFile {
+		return f, newUploadError(http.StatusRequestEntityTooLarge,
+			"file %s is too large, max %d bytes", f.Filename, maxFile)
+	}
+	f.Size = size
+	f.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return f, errors.WithStack(err)
+	}
+	return f, nil
+}
+
+// MatchMediaType returns true if the media type is listed,
+// types ending with "/*" match all subtypes
+func MatchMediaType(types []string, mediaType string) bool {
+	for _, t := range types {
+		if strings.HasSuffix(t, "/*") {
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+			continue
+		}
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanup closes and removes temp files
+func (u *Upload) cleanup(r *http.Request) {
+	for _, f := range u.Files {
+		_ = f.File.Close()
+	}
+	err := os.RemoveAll(u.dir)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Stack().Err(errors.WithStack(err)).
+			Msg(fmt.Sprintf("remove %s", u.dir))
+	}
+}
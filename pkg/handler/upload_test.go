@@ -0,0 +1,135 @@
+package handler_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+type uploadPart struct {
+	field, filename, data string
+}
+
+func multipartRequest(t *testing.T, parts ...uploadPart) *http.Request {
+	body := bytes.Buffer{}
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		if p.filename == "" {
+			require.NoError(t, mw.WriteField(p.field, p.data))
+			continue
+		}
+		fw, err := mw.CreateFormFile(p.field, p.filename)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(p.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+	req := httptest.NewRequest("POST", "/upload", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUpload(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	var paths []string
+	h.Router.HandlerFunc("POST", "/upload", h.Upload(
+		func(w http.ResponseWriter, r *http.Request, u *handler.Upload) {
+			resp := share.UploadResponse{}
+			for _, f := range u.Files {
+				// Files are open and positioned at the start
+				b, err := io.ReadAll(f.File)
+				require.NoError(t, err)
+				require.Equal(t, f.Size, int64(len(b)))
+				paths = append(paths, f.File.Name())
+				resp.Files = append(resp.Files, f.UploadedFile)
+			}
+			require.Equal(t, "bar", u.Values.Get("foo"))
+			h.JSON(http.StatusOK, w, r, resp)
+		}, &handler.UploadOptions{
+			MaxFileBytes:  1024,
+			MaxTotalBytes: 4096,
+			MaxFiles:      2,
+			ContentTypes:  []string{"text/*"},
+		}))
+
+	upload := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	text := strings.Repeat("hello ", 100)
+	rec := upload(multipartRequest(t,
+		uploadPart{"foo", "", "bar"},
+		uploadPart{"file", "../a.txt", text},
+		uploadPart{"file", "b.txt", "b"},
+	))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	resp := share.UploadResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Files, 2)
+	sum := sha256.Sum256([]byte(text))
+	require.Equal(t, share.UploadedFile{
+		Field:       "file",
+		Filename:    "a.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Size:        int64(len(text)),
+		SHA256:      hex.EncodeToString(sum[:]),
+	}, resp.Files[0])
+
+	// Temp files are removed when the request ends
+	require.Len(t, paths, 2)
+	for _, p := range paths {
+		_, err = os.Stat(p)
+		require.True(t, os.IsNotExist(err), p)
+	}
+
+	// Rejected
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 16)
+	for _, tc := range []struct {
+		name  string
+		parts []uploadPart
+		code  int
+	}{
+		{"type", []uploadPart{{"file", "a.txt", png}},
+			http.StatusUnsupportedMediaType},
+		{"file size", []uploadPart{{"file", "a.txt", strings.Repeat("a", 1025)}},
+			http.StatusRequestEntityTooLarge},
+		{"files", []uploadPart{
+			{"file", "a.txt", "a"}, {"file", "b.txt", "b"}, {"file", "c.txt", "c"},
+		}, http.StatusRequestEntityTooLarge},
+		{"total size", []uploadPart{{"foo", "", strings.Repeat("a", 4096)}},
+			http.StatusRequestEntityTooLarge},
+	} {
+		rec = upload(multipartRequest(t, tc.parts...))
+		require.Equal(t, tc.code, rec.Code, tc.name)
+	}
+
+	// Including temp files of rejected requests
+	entries, err := os.ReadDir(filepath.Join(conf.Dir(), "tmp"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	rec = upload(req)
+	require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+}
@@ -0,0 +1,296 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+)
+
+// TagValidate lists the validation rules for a field, for example
+//
+//	Name  string `json:"name" validate:"required,max=64"`
+//	Color string `json:"color" validate:"enum=red|green|blue"`
+//	Code  string `json:"code" validate:"pattern=^[A-Z]{3}$"`
+//
+// Rules are comma separated. The pattern rule must be last,
+// the regexp may contain commas
+const TagValidate = "validate"
+
+// ValidationError lists every field that failed validation
+type ValidationError struct {
+	Errors []share.FieldError
+}
+
+// Add a field error
+func (e *ValidationError) Add(field, message string) {
+	e.Errors = append(e.Errors, share.FieldError{
+		Field:   field,
+		Message: message,
+	})
+}
+
+func (e *ValidationError) Error() string {
+	s := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		s[i] = fmt.Sprintf("%s %s", fe.Field, fe.Message)
+	}
+	return fmt.Sprintf("validation failed: %s", strings.Join(s, "; "))
+}
+
+// Validate checks the "validate" tags on the struct pointed to by v,
+// nested structs, pointers and slices of structs are validated recursively.
+// Returns *ValidationError listing every field error, or nil
+func Validate(v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return errors.Errorf("validate target must be a struct")
+	}
+	verr := &ValidationError{}
+	validateStruct(rv, "", verr)
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func validateStruct(rv reflect.Value, path string, verr *ValidationError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		fv := rv.Field(i)
+
+		name := jsonName(field)
+		if name == "-" {
+			continue
+		}
+		fieldPath := name
+		if field.Anonymous && fv.Kind() == reflect.Struct {
+			// Embedded fields are promoted
+			fieldPath = path
+		} else if path != "" {
+			fieldPath = path + "." + name
+		}
+
+		tag := field.Tag.Get(TagValidate)
+		if tag != "" {
+			ok := validateField(fv, fieldPath, tag, verr)
+			if !ok {
+				// Skip nested validation if the field itself is invalid
+				continue
+			}
+		}
+		validateNested(fv, fieldPath, verr)
+	}
+}
+
+// validateNested descends into structs, pointers and slices
+func validateNested(fv reflect.Value, path string, verr *ValidationError) {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !fv.IsNil() {
+			validateNested(fv.Elem(), path, verr)
+		}
+	case reflect.Struct:
+		validateStruct(fv, path, verr)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			validateNested(fv.Index(i), fmt.Sprintf("%s[%d]", path, i), verr)
+		}
+	}
+}
+
+// validateField applies the rules in tag to fv,
+// returns false if any rule failed
+func validateField(
+	fv reflect.Value, path, tag string, verr *ValidationError) bool {
+
+	rules := parseRules(tag)
+
+	if isZero(fv) {
+		if _, ok := rules["required"]; ok {
+			verr.Add(path, "is required")
+			return false
+		}
+		if isAbsent(fv) {
+			// Other rules only apply to values that were set
+			return true
+		}
+	}
+
+	v := reflect.Indirect(fv)
+	valid := true
+	for _, rule := range ruleOrder {
+		arg, ok := rules[rule]
+		if !ok {
+			continue
+		}
+		msg := ""
+		switch rule {
+		case "min", "max":
+			msg = validateRange(v, rule, arg)
+		case "enum":
+			msg = validateEnum(v, arg)
+		case "pattern":
+			msg = validatePattern(v, arg)
+		}
+		if msg != "" {
+			verr.Add(path, msg)
+			valid = false
+		}
+	}
+	return valid
+}
+
+// ruleOrder makes error messages deterministic
+var ruleOrder = []string{"min", "max", "enum", "pattern"}
+
+func parseRules(tag string) map[string]string {
+	rules := make(map[string]string)
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "pattern=") {
+			rule, tag = tag, ""
+		} else {
+			i := strings.Index(tag, ",")
+			if i < 0 {
+				rule, tag = tag, ""
+			} else {
+				rule, tag = tag[:i], tag[i+1:]
+			}
+		}
+		kv := strings.SplitN(strings.TrimSpace(rule), "=", 2)
+		if len(kv) == 2 {
+			rules[kv[0]] = kv[1]
+		} else {
+			rules[kv[0]] = ""
+		}
+	}
+	return rules
+}
+
+func isZero(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return fv.IsNil()
+	case reflect.Map, reflect.Slice:
+		return fv.Len() == 0
+	}
+	return fv.IsZero()
+}
+
+// isAbsent returns true for zero values that can't be told apart from a
+// field that was omitted, i.e. nil pointers, slices and maps, and empty strings.
+// Zero numbers and false are present, e.g. 0 fails the min=1 rule
+func isAbsent(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return fv.IsNil()
+	case reflect.String:
+		return fv.Len() == 0
+	}
+	return false
+}
+
+// validateRange compares numbers by value,
+// and strings, slices and maps by length
+func validateRange(v reflect.Value, rule, arg string) string {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return fmt.Sprintf("has invalid %s rule %q", rule, arg)
+	}
+
+	var n float64
+	unit := ""
+	switch v.Kind() {
+	case reflect.String:
+		n = float64(len([]rune(v.String())))
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n = float64(v.Len())
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return fmt.Sprintf("does not support the %s rule", rule)
+	}
+
+	if rule == "min" && n < limit {
+		if unit == "" {
+			return fmt.Sprintf("must be at least %s", arg)
+		}
+		return fmt.Sprintf("must have at least %s%s", arg, unit)
+	}
+	if rule == "max" && n > limit {
+		if unit == "" {
+			return fmt.Sprintf("must be at most %s", arg)
+		}
+		return fmt.Sprintf("must have at most %s%s", arg, unit)
+	}
+	return ""
+}
+
+func validateEnum(v reflect.Value, arg string) string {
+	allowed := strings.Split(arg, "|")
+	s := fmt.Sprintf("%v", v.Interface())
+	for _, a := range allowed {
+		if s == a {
+			return ""
+		}
+	}
+	return fmt.Sprintf("must be one of %s", strings.Join(allowed, ", "))
+}
+
+// patterns caches compiled regexps by pattern
+var patterns sync.Map
+
+func validatePattern(v reflect.Value, arg string) string {
+	if v.Kind() != reflect.String {
+		return "does not support the pattern rule"
+	}
+	var re *regexp.Regexp
+	cached, ok := patterns.Load(arg)
+	if ok {
+		re = cached.(*regexp.Regexp)
+	} else {
+		var err error
+		re, err = regexp.Compile(arg)
+		if err != nil {
+			return fmt.Sprintf("has invalid pattern rule %q", arg)
+		}
+		patterns.Store(arg, re)
+	}
+	if !re.MatchString(v.String()) {
+		return fmt.Sprintf("must match pattern %s", arg)
+	}
+	return ""
+}
+
+// jsonName returns the JSON property name for the field,
+// falls back to the bind tags for fields that are not in the body
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name != "" {
+		return name
+	}
+	for _, tag := range []string{TagParam, TagQuery, TagForm} {
+		name = field.Tag.Get(tag)
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
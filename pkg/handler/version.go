@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/mozey/httprouter-util/pkg/share"
+)
+
+// Versions returns a handler that calls the handler for the API version
+// set on the request context by the version middleware.
+// The handler for the highest version not greater than
+// the requested version is used, so routes that didn't change between
+// versions only need one handler. Requests without a version use the
+// handler for the lowest version
+func (h *Handler) Versions(handlers map[int]http.HandlerFunc) http.HandlerFunc {
+	lowest := 0
+	for version := range handlers {
+		if lowest == 0 || version < lowest {
+			lowest = version
+		}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		requested, ok := r.Context().Value(share.ContextAPIVersion).(int)
+
+		selected := lowest
+		for version := range handlers {
+			if ok && version <= requested && version > selected {
+				selected = version
+			}
+		}
+		handlers[selected](w, r)
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// WebSocketFunc handles an upgraded connection,
+// the connection is closed when the func returns
+type WebSocketFunc func(conn *websocket.Conn, r *http.Request)
+
+type WebSocketOptions struct {
+	// CheckOrigin returns true if the request Origin header is acceptable,
+	// by default the origin host must match the request host
+	CheckOrigin func(r *http.Request) bool
+	// CloseTimeout is the deadline for clients to reply to the close frame
+	// sent on shutdown, default 5s
+	CloseTimeout time.Duration
+	// Route describes the route in the Registry
+	Route *RouteOptions
+}
+
+// WebSocket registers a GET route that upgrades requests to WebSockets.
+// The route passes through the same middleware as other routes.
+// Connections are tracked, on shutdown a close frame is sent to each,
+// see Wait
+func (h *Handler) WebSocket(path string, fn WebSocketFunc, o *WebSocketOptions) {
+	if o == nil {
+		o = &WebSocketOptions{}
+	}
+	closeTimeout := o.CloseTimeout
+	if closeTimeout <= 0 {
+		closeTimeout = 5 * time.Second
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: o.CheckOrigin,
+	}
+
+	h.HandlerFunc("GET", path, func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		// Headers set by middleware are not written on upgrade,
+		// pass the request ID through explicitly
+		header := http.Header{}
+		requestID := w.Header().Get(share.HeaderXRequestID)
+		if requestID != "" {
+			header.Set(share.HeaderXRequestID, requestID)
+		}
+
+		// Track the connection before it's hijacked, Server.Shutdown waits
+		// for the request until then, so Add happens before Wait
+		h.websockets.Add(1)
+		defer h.websockets.Done()
+
+		// Upgrade writes an error response if the handshake fails
+		conn, err := upgrader.Upgrade(w, r, header)
+		if err != nil {
+			logResponse(r, log.Ctx(ctx).Error().Err(err),
+				http.StatusBadRequest, err.Error())
+			return
+		}
+		logResponse(r, log.Ctx(ctx).Info(),
+			http.StatusSwitchingProtocols, "websocket connected")
+
+		// Clear deadlines set by the server ReadTimeout and WriteTimeout,
+		// the handler must set its own
+		_ = conn.NetConn().SetDeadline(time.Time{})
+
+		// Close the connection on shutdown
+		finished := make(chan struct{})
+		go func() {
+			select {
+			case <-h.Done():
+				msg := websocket.FormatCloseMessage(
+					websocket.CloseGoingAway, "server shutting down")
+				err := conn.WriteControl(websocket.CloseMessage, msg,
+					time.Now().Add(closeTimeout))
+				if err != nil {
+					_ = conn.Close()
+					return
+				}
+				// Wait for the client to reply, the read loop
+				// in fn gets the reply and returns
+				select {
+				case <-finished:
+				case <-time.After(closeTimeout):
+					_ = conn.Close()
+				}
+			case <-finished:
+			}
+		}()
+
+		fn(conn, r)
+		close(finished)
+
+		err = conn.Close()
+		if err != nil {
+			log.Ctx(ctx).Debug().Err(errors.WithStack(err)).Msg("")
+		}
+		log.Ctx(ctx).Info().Msg("websocket closed")
+	}, o.Route)
+}
+
+// Wait for tracked connections to close after Shutdown,
+// Server.Shutdown does not wait for hijacked connections, e.g. WebSockets
+func (h *Handler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.websockets.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
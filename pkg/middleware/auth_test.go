@@ -66,13 +66,37 @@ func TestAuth(t *testing.T) {
 	h.HTTPHandler.ServeHTTP(rec, req)
 
 	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "Signed in as")
 
-	u.Path = "/www/index.html"
+	// Public routes with a token
+	req = httptest.NewRequest("GET", "/index.html?token=123", nil)
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "Signed in as demo")
+	req = httptest.NewRequest("GET", "/index.html?token=abc", nil)
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "Signed in as")
+
+	u.Path = "/www/data/go.txt"
 	req, err = http.NewRequest("GET", u.String(), nil)
 	require.NoError(t, err)
 
 	rec = httptest.NewRecorder()
 	h.HTTPHandler.ServeHTTP(rec, req)
 
-	require.Equal(t, http.StatusMovedPermanently, rec.Code)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// Admin routes require a token
+	req = httptest.NewRequest("GET", "/admin/routes", nil)
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	req = httptest.NewRequest("GET", "/admin/routes?token=123", nil)
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), `"pattern": "/www/*filepath"`)
 }
@@ -0,0 +1,461 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/pkg/errors"
+)
+
+// Content codings supported by Compress
+const (
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+	EncodingGzip   = "gzip"
+)
+
+// CompressLevel is mapped to the equivalent level of each encoder
+type CompressLevel int
+
+const (
+	// CompressDefault balances speed and size for dynamic responses
+	CompressDefault CompressLevel = iota
+	CompressFastest
+	CompressBest
+)
+
+// DefaultCompressTypes are compressed if CompressOptions.ContentTypes is empty
+var DefaultCompressTypes = []string{
+	"text/*",
+	"application/json",
+	"application/x-ndjson",
+	"application/javascript",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+	"image/x-icon",
+}
+
+// skipTypes are never compressed, even if they match
+// CompressOptions.ContentTypes. Most are compressed already.
+// Event streams are skipped because the encoder buffers output,
+// and events must reach the client when the handler flushes
+var skipTypes = []string{
+	"text/event-stream",
+	"image/jpeg",
+	"image/png",
+	"image/gif",
+	"image/webp",
+	"image/avif",
+	"video/*",
+	"audio/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/zstd",
+}
+
+// CompressSkipper lists requests that must not be compressed,
+// return true if compression should be skipped
+func CompressSkipper(r *http.Request) bool {
+	// WebSocket connections are hijacked, and use their own compression.
+	// Event streams are skipped by the response Content-Type
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+type CompressOptions struct {
+	Skipper func(r *http.Request) bool
+	// MinSize in bytes, smaller responses are not compressed, default 1024
+	MinSize int
+	// ContentTypes that may be compressed, default DefaultCompressTypes.
+	// Types ending with "/*" match all subtypes
+	ContentTypes []string
+	Level        CompressLevel
+	// Encodings in order of preference, used if the client accepts
+	// more than one with the same quality, default br, zstd, gzip
+	Encodings []string
+}
+
+// contextKeyCompress is the request context key for the compression state
+type contextKeyCompress struct{}
+
+// compressState is shared by the middleware and NoCompression
+type compressState struct {
+	disabled bool
+}
+
+// NoCompression opts a route out of compression,
+// e.g. responses that support range requests
+func NoCompression(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, ok := r.Context().Value(contextKeyCompress{}).(*compressState)
+		if ok {
+			state.disabled = true
+		}
+		next(w, r)
+	}
+}
+
+// compressor creates pooled encoders for a content coding
+type compressor struct {
+	pool sync.Pool
+}
+
+func (c *compressor) get(w io.Writer) encoder {
+	enc := c.pool.Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+func (c *compressor) put(enc encoder) {
+	c.pool.Put(enc)
+}
+
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+func newCompressors(level CompressLevel) map[string]*compressor {
+	gzipLevel := gzip.DefaultCompression
+	brotliLevel := 5
+	zstdLevel := zstd.SpeedDefault
+	switch level {
+	case CompressFastest:
+		gzipLevel = gzip.BestSpeed
+		brotliLevel = brotli.BestSpeed
+		zstdLevel = zstd.SpeedFastest
+	case CompressBest:
+		gzipLevel = gzip.BestCompression
+		brotliLevel = brotli.BestCompression
+		zstdLevel = zstd.SpeedBestCompression
+	}
+
+	c := make(map[string]*compressor)
+	c[EncodingGzip] = &compressor{pool: sync.Pool{New: func() interface{} {
+		// Level is valid
+		enc, _ := gzip.NewWriterLevel(nil, gzipLevel)
+		return enc
+	}}}
+	c[EncodingBrotli] = &compressor{pool: sync.Pool{New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotliLevel)
+	}}}
+	c[EncodingZstd] = &compressor{pool: sync.Pool{New: func() interface{} {
+		// Options are valid
+		enc, _ := zstd.NewWriter(nil,
+			zstd.WithEncoderLevel(zstdLevel),
+			// Encode on the request goroutine
+			zstd.WithEncoderConcurrency(1))
+		return enc
+	}}}
+	return c
+}
+
+// etagEncoding appends the content coding to a strong ETag,
+// e.g. "abc" becomes "abc-gzip". Weak tags are not changed
+func etagEncoding(etag, coding string) string {
+	if !strings.HasPrefix(etag, "\"") || !strings.HasSuffix(etag, "\"") ||
+		len(etag) < 2 {
+		return etag
+	}
+	return strings.TrimSuffix(etag, "\"") + "-" + coding + "\""
+}
+
+// trimETagEncodings removes content coding suffixes from the ETags listed in
+// a precondition header, see etagEncoding. Removed codings are set in codings
+func trimETagEncodings(
+	header string, encodings []string, codings map[string]bool) string {
+
+	tags := strings.Split(header, ",")
+	for i, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		for _, coding := range encodings {
+			suffix := "-" + coding + "\""
+			if strings.HasPrefix(tag, "\"") && len(tag) > len(suffix) &&
+				strings.HasSuffix(tag, suffix) {
+				tag = strings.TrimSuffix(tag, suffix) + "\""
+				codings[coding] = true
+				break
+			}
+		}
+		tags[i] = tag
+	}
+	return strings.Join(tags, ", ")
+}
+
+// Compress responses with the best encoding accepted by the client,
+// see CompressOptions.
+// Strong ETags of compressed responses get the coding as a suffix,
+// the suffix is removed from If-Match and If-None-Match request headers
+func Compress(next http.Handler, o *CompressOptions) http.Handler {
+	minSize := o.MinSize
+	if minSize <= 0 {
+		minSize = 1024
+	}
+	contentTypes := o.ContentTypes
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultCompressTypes
+	}
+	encodings := o.Encodings
+	if len(encodings) == 0 {
+		encodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+	}
+	compressors := newCompressors(o.Level)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.Skipper != nil {
+			if o.Skipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		// Response depends on the request header,
+		// even if this response is not compressed
+		handler.Vary(w.Header(), "Accept-Encoding")
+
+		// Handlers compare preconditions with the ETag of the
+		// uncompressed representation
+		codings := make(map[string]bool)
+		for _, key := range []string{"If-Match", "If-None-Match"} {
+			value := r.Header.Get(key)
+			if value != "" {
+				r.Header.Set(key, trimETagEncodings(value, encodings, codings))
+			}
+		}
+
+		// Compressed ranges are meaningless to the client
+		if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Negotiate, ties are resolved in order of preference
+		acceptEncoding := r.Header.Get("Accept-Encoding")
+		coding := ""
+		best := 0.0
+		for _, encoding := range encodings {
+			if compressors[encoding] == nil {
+				continue
+			}
+			q := handler.EncodingQ(acceptEncoding, encoding)
+			if q > best {
+				best = q
+				coding = encoding
+			}
+		}
+		if coding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		state := &compressState{}
+		r = r.WithContext(
+			context.WithValue(r.Context(), contextKeyCompress{}, state))
+		cw := &compressWriter{
+			ResponseWriter: w,
+			state:          state,
+			coding:         coding,
+			compressor:     compressors[coding],
+			minSize:        minSize,
+			contentTypes:   contentTypes,
+			etagCoded:      codings[coding],
+			code:           http.StatusOK,
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter buffers the response until MinSize is reached,
+// then decides if the response should be compressed
+type compressWriter struct {
+	http.ResponseWriter
+	state        *compressState
+	coding       string
+	compressor   *compressor
+	minSize      int
+	contentTypes []string
+	// etagCoded is true if If-None-Match listed an ETag with the coding
+	etagCoded bool
+
+	code        int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	enc         encoder
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+	if code < http.StatusOK {
+		// Informational responses, e.g. 103 Early Hints
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.wroteHeader = true
+	cw.code = code
+	if code == http.StatusNotModified && cw.etagCoded {
+		// Same validator as the compressed 200 response
+		etag := cw.Header().Get("ETag")
+		if etag != "" {
+			cw.Header().Set("ETag", etagEncoding(etag, cw.coding))
+		}
+	}
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		// No body
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.decided {
+		if cw.enc != nil {
+			return cw.enc.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	cl, _ := strconv.Atoi(cw.Header().Get("Content-Length"))
+	if len(cw.buf) < cw.minSize && cl == 0 {
+		// Wait for more data
+		return len(b), nil
+	}
+	err := cw.decide()
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// compressible returns true if the response should be compressed
+func (cw *compressWriter) compressible() bool {
+	header := cw.Header()
+	if cw.state.disabled ||
+		header.Get("Content-Encoding") != "" ||
+		header.Get("Content-Range") != "" ||
+		cw.code == http.StatusPartialContent {
+		return false
+	}
+	cl, _ := strconv.Atoi(header.Get("Content-Length"))
+	if cl > 0 && cl < cw.minSize {
+		return false
+	}
+	if len(cw.buf) == 0 {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+		header.Set("Content-Type", contentType)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return !handler.MatchMediaType(skipTypes, mediaType) &&
+		handler.MatchMediaType(cw.contentTypes, mediaType)
+}
+
+// decide if the response is compressed, and write the buffer
+func (cw *compressWriter) decide() error {
+	cw.decided = true
+	header := cw.Header()
+	if cw.compressible() {
+		header.Set("Content-Encoding", cw.coding)
+		header.Del("Content-Length")
+		header.Del("Accept-Ranges")
+		// Representation changed, the strong validator gets the coding as
+		// a suffix. Compress removes it from preconditions on requests
+		etag := header.Get("ETag")
+		if etag != "" {
+			header.Set("ETag", etagEncoding(etag, cw.coding))
+		}
+		cw.enc = cw.compressor.get(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.code)
+
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.enc != nil {
+		_, err := cw.enc.Write(buf)
+		return errors.WithStack(err)
+	}
+	_, err := cw.ResponseWriter.Write(buf)
+	return errors.WithStack(err)
+}
+
+// Close writes buffered data and closes the encoder
+func (cw *compressWriter) Close() {
+	if !cw.decided {
+		if !cw.wroteHeader && len(cw.buf) == 0 {
+			// Nothing written, e.g. handler hijacked the connection
+			return
+		}
+		// Less than MinSize written
+		cw.state.disabled = cw.state.disabled || len(cw.buf) < cw.minSize
+		_ = cw.decide()
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.compressor.put(cw.enc)
+		cw.enc = nil
+	}
+}
+
+// Flush compresses buffered data, streamed responses are not buffered
+// until MinSize is reached
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.decided {
+		_ = cw.decide()
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack the connection, the response is not compressed
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.Errorf("hijack not supported")
+	}
+	cw.state.disabled = true
+	cw.decided = true
+	return hj.Hijack()
+}
+
+// Unwrap is used by http.ResponseController
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,228 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mozey/httprouter-util/internal/app"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/middleware"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, coding string, b []byte) string {
+	var r io.Reader
+	var err error
+	switch coding {
+	case middleware.EncodingBrotli:
+		r = brotli.NewReader(bytes.NewReader(b))
+	case middleware.EncodingZstd:
+		r, err = zstd.NewReader(bytes.NewReader(b))
+	case middleware.EncodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(b))
+	default:
+		return string(b)
+	}
+	require.NoError(t, err)
+	decoded, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(decoded)
+}
+
+// TestCompressApp, see SetupMiddleware in internal/app/handler.go
+func TestCompressApp(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	// Large JSON response
+	req := httptest.NewRequest("GET", "/items?token=123&limit=100", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0.5, br, zstd;q=0.8")
+	rec := httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	require.Contains(t, decode(t, "br", rec.Body.Bytes()), `"item 100"`)
+
+	// Small responses are not compressed,
+	// the index page is smaller than MinSize
+	req = httptest.NewRequest("GET", "/index.html", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get("Content-Encoding"))
+	require.Contains(t, rec.Body.String(), "It works!")
+}
+
+func TestCompress(t *testing.T) {
+	text := strings.Repeat("foo bar baz ", 200)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"abc"`)
+		_, _ = w.Write([]byte(text))
+	})
+	mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 0; i < 3; i++ {
+			_, _ = w.Write([]byte("{}\n"))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(text))
+		w.(http.Flusher).Flush()
+	})
+	mux.HandleFunc("/jpeg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write([]byte(text))
+	})
+	mux.HandleFunc("/range", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "range.txt", time.Time{},
+			strings.NewReader(text))
+	})
+	mux.HandleFunc("/opt-out", middleware.NoCompression(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(text))
+		}))
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("foo"))
+	})
+	handler := middleware.Compress(mux, &middleware.CompressOptions{
+		MinSize: 100,
+		Level:   middleware.CompressFastest,
+	})
+
+	get := func(path, acceptEncoding, rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Negotiation with q-values, ties resolved in order of preference
+	for _, tc := range []struct {
+		acceptEncoding string
+		coding         string
+	}{
+		{"gzip, zstd, br", "br"},
+		{"gzip, zstd", "zstd"},
+		{"gzip;q=1, zstd;q=0.9, br;q=0.1", "gzip"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"*", "br"},
+		{"br;q=0, *", "zstd"},
+		{"identity", ""},
+		{"", ""},
+	} {
+		rec := get("/text", tc.acceptEncoding, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, tc.coding, rec.Header().Get("Content-Encoding"),
+			tc.acceptEncoding)
+		require.Equal(t, text, decode(t, tc.coding, rec.Body.Bytes()))
+		require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+		if tc.coding != "" {
+			// Strong validator for the compressed representation
+			require.Equal(t, `"abc-`+tc.coding+`"`, rec.Header().Get("ETag"))
+		}
+	}
+
+	// Streams are compressed as they are flushed
+	rec := get("/stream", "gzip", "")
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	require.Equal(t, "{}\n{}\n{}\n", decode(t, "gzip", rec.Body.Bytes()))
+
+	// Skipped
+	for _, tc := range []struct {
+		path, rangeHeader string
+	}{
+		{"/jpeg", ""},
+		{"/events", ""},
+		{"/range", "bytes=0-9"},
+		{"/opt-out", ""},
+		{"/small", ""},
+	} {
+		rec := get(tc.path, "gzip, br", tc.rangeHeader)
+		require.Empty(t, rec.Header().Get("Content-Encoding"), tc.path)
+	}
+	rec = get("/range", "gzip", "bytes=0-9")
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	require.Equal(t, text[:10], rec.Body.String())
+}
+
+// TestCompressPreconditions sends the ETag of a compressed response back
+// through the app middleware, see CheckPreconditions in pkg/handler
+func TestCompressPreconditions(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	text := strings.Repeat("foo bar baz ", 200)
+	o := &handler.ResponseOptions{ETag: handler.ETagStrong}
+	h.HandlerFunc("GET", "/etag", func(w http.ResponseWriter, r *http.Request) {
+		h.WriteWithOptions(http.StatusOK, "text/plain", w, r, []byte(text), o)
+	}, &handler.RouteOptions{Policy: share.PolicyPublic})
+	h.HandlerFunc("PUT", "/etag", func(w http.ResponseWriter, r *http.Request) {
+		if !h.CheckPreconditions(w, r, handler.ETag([]byte(text), false),
+			time.Time{}) {
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}, &handler.RouteOptions{Policy: share.PolicyPublic})
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	req := httptest.NewRequest("GET", "/etag", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	etag := rec.Header().Get("ETag")
+	require.Equal(t, strings.TrimSuffix(handler.ETag([]byte(text), false),
+		`"`)+`-gzip"`, etag)
+
+	// Revalidate
+	req = httptest.NewRequest("GET", "/etag", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotModified, rec.Code)
+	require.Equal(t, etag, rec.Header().Get("ETag"))
+
+	// Strong If-Match with the tag of the compressed response
+	for _, acceptEncoding := range []string{"gzip", ""} {
+		req = httptest.NewRequest("PUT", "/etag", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		req.Header.Set("If-Match", etag)
+		rec = httptest.NewRecorder()
+		h.HTTPHandler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNoContent, rec.Code, acceptEncoding)
+	}
+
+	// Weak tags never match
+	req = httptest.NewRequest("PUT", "/etag", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("If-Match", "W/"+etag)
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+}
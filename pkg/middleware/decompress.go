@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/pkg/errors"
+)
+
+// Content codings supported by Decompress, in addition to those of Compress
+const (
+	EncodingDeflate = "deflate"
+)
+
+// decompressEncodings is set as the Accept-Encoding response header
+// on 415 Unsupported Media Type, see RFC 7694
+var decompressEncodings = []string{
+	EncodingGzip, EncodingDeflate, EncodingBrotli, EncodingZstd,
+}
+
+// maxZstdWindow limits the memory used to decode zstd frames
+const maxZstdWindow = 8 << 20
+
+type DecompressOptions struct {
+	H       *handler.Handler
+	Skipper func(r *http.Request) bool
+}
+
+// Decompress request bodies with Content-Encoding gzip, deflate, br or zstd.
+// Use it outside the MaxBytes middleware,
+// then the limit applies to the decompressed body
+func Decompress(next http.Handler, o *DecompressOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.Skipper != nil {
+			if o.Skipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		contentEncoding := r.Header.Get("Content-Encoding")
+		if contentEncoding == "" || r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Codings are listed in the order they were applied
+		codings := strings.Split(contentEncoding, ",")
+		body := r.Body
+		closers := []io.Closer{r.Body}
+		for i := len(codings) - 1; i >= 0; i-- {
+			coding := strings.ToLower(strings.TrimSpace(codings[i]))
+			if coding == "identity" {
+				continue
+			}
+			decoded, err := decoder(coding, body)
+			if err != nil {
+				closeAll(closers)
+				if errors.Cause(err) == errUnsupportedEncoding {
+					w.Header().Set("Accept-Encoding",
+						strings.Join(decompressEncodings, ", "))
+					o.H.JSON(http.StatusUnsupportedMediaType, w, r,
+						errors.Errorf("unsupported content encoding %s", coding))
+					return
+				}
+				o.H.JSON(http.StatusBadRequest, w, r, err)
+				return
+			}
+			body = decoded
+			closers = append(closers, decoded)
+		}
+
+		r.Body = &decompressBody{Reader: body, closers: closers}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		next.ServeHTTP(w, r)
+	})
+}
+
+var errUnsupportedEncoding = errors.New("unsupported encoding")
+
+// decoder for the content coding
+func decoder(coding string, body io.Reader) (io.ReadCloser, error) {
+	switch coding {
+	case EncodingGzip, "x-gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return gz, nil
+
+	case EncodingDeflate:
+		// Should be zlib, but some clients send raw deflate
+		br := bufio.NewReader(body)
+		header, err := br.Peek(2)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return zr, nil
+		}
+		return flate.NewReader(br), nil
+
+	case EncodingBrotli:
+		return io.NopCloser(brotli.NewReader(body)), nil
+
+	case EncodingZstd:
+		zr, err := zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxWindow(maxZstdWindow))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return nil, errors.WithStack(errUnsupportedEncoding)
+}
+
+// decompressBody closes the decoders and the original body
+type decompressBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (b *decompressBody) Close() error {
+	return closeAll(b.closers)
+}
+
+// closeAll in reverse order, returns the first error
+func closeAll(closers []io.Closer) (err error) {
+	for i := len(closers) - 1; i >= 0; i-- {
+		cerr := closers[i].Close()
+		if err == nil {
+			err = cerr
+		}
+	}
+	return errors.WithStack(err)
+}
@@ -0,0 +1,125 @@
+package middleware_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mozey/httprouter-util/internal/app"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/middleware"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t *testing.T, coding string, b []byte) []byte {
+	buf := bytes.Buffer{}
+	var w io.WriteCloser
+	var err error
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	}
+	require.NoError(t, err)
+	_, err = w.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestDecompress(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := handler.NewHandler(conf)
+	defer h.Cleanup()
+
+	// Echo the request body
+	var echo http.Handler = http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			b, err := h.GetBody(r)
+			if err != nil {
+				h.JSON(http.StatusInternalServerError, w, r, err)
+				return
+			}
+			_, _ = w.Write(b)
+		})
+	echo = middleware.MaxBytes(echo, &middleware.MaxBytesOptions{
+		MaxBytes: 1024,
+	})
+	handler := middleware.Decompress(echo, &middleware.DecompressOptions{
+		H: h,
+	})
+
+	post := func(contentEncoding string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", contentEncoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	data := []byte(`{"foo": "bar"}`)
+	for _, tc := range []struct {
+		contentEncoding string
+		body            []byte
+	}{
+		{"gzip", encode(t, "gzip", data)},
+		{"deflate", encode(t, "zlib", data)},
+		{"deflate", encode(t, "flate", data)},
+		{"br", encode(t, "br", data)},
+		{"zstd", encode(t, "zstd", data)},
+		// Applied in order
+		{"gzip, br", encode(t, "br", encode(t, "gzip", data))},
+		{"identity", data},
+	} {
+		rec := post(tc.contentEncoding, tc.body)
+		require.Equal(t, http.StatusOK, rec.Code, tc.contentEncoding)
+		require.Equal(t, string(data), rec.Body.String(), tc.contentEncoding)
+	}
+
+	// Limit applies to the decompressed body
+	bomb := encode(t, "gzip", make([]byte, 256*1024))
+	require.Less(t, len(bomb), 1024)
+	rec := post("gzip", bomb)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	// Invalid and unsupported encodings
+	rec = post("gzip", data)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = post("compress", data)
+	require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	require.Equal(t, "gzip, deflate, br, zstd", rec.Header().Get("Accept-Encoding"))
+}
+
+// TestDecompressApp, see SetupMiddleware in internal/app/handler.go
+func TestDecompressApp(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	req := httptest.NewRequest("POST", "/api?token=123",
+		bytes.NewReader(encode(t, "gzip", []byte(`{"foo": "bar"}`))))
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "Welcome")
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/rs/zerolog/log"
+)
+
+// cannedResponse matches the plain text responses net/http writes to the
+// connection when a request can't be read, e.g. header too large (431),
+// or a malformed request (400). These never reach the handlers
+var cannedResponse = regexp.MustCompile(
+	`(?s)^HTTP/1\.1 (\d{3}) [^\r\n]*\r\n` +
+		`Content-Type: text/plain; charset=utf-8\r\n` +
+		`Connection: close\r\n\r\n(.*)$`)
+
+var httpPrefix = []byte("HTTP/1.1 ")
+
+// Listener wraps connections accepted by l, so server-level errors get
+// the same JSON response body and request ID as errors from handlers.
+// Plain text responses written by net/http are replaced,
+// and a 408 response is written if the request head is not read before
+// the server ReadTimeout. net/http closes the connection without a response
+// in that case. Errors are logged.
+// The ConnState hook of srv is wrapped, connections are only tracked while
+// the request head is read, and not after they are hijacked, e.g. WebSockets
+func Listener(srv *http.Server, l net.Listener) net.Listener {
+	connState := srv.ConnState
+	srv.ConnState = func(c net.Conn, state http.ConnState) {
+		if ec, ok := c.(*errorConn); ok {
+			ec.setState(state)
+		}
+		if connState != nil {
+			connState(c, state)
+		}
+	}
+	return &errorListener{Listener: l}
+}
+
+type errorListener struct {
+	net.Listener
+}
+
+func (l *errorListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return c, err
+	}
+	return &errorConn{Conn: c, tracking: 1}, nil
+}
+
+// errorConn tracks if a request head is being read,
+// a head ends with an empty line
+type errorConn struct {
+	net.Conn
+	// tracking is 1 until the request head is read,
+	// and again when the connection is idle. Zero once hijacked
+	tracking int32
+	// hijacked is 1 after the handler takes over the connection
+	hijacked int32
+
+	mu sync.Mutex
+	// head is true if bytes of the next request head have been read
+	head bool
+	// newlines at the end of the bytes read, two ends the head
+	newlines int
+	// closed is true after an error response
+	closed bool
+}
+
+// setState is called by the server ConnState hook
+func (c *errorConn) setState(state http.ConnState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch state {
+	case http.StateIdle:
+		// Waiting for the next request head
+		c.head, c.newlines = false, 0
+		atomic.StoreInt32(&c.tracking, 1)
+	case http.StateHijacked:
+		atomic.StoreInt32(&c.hijacked, 1)
+		atomic.StoreInt32(&c.tracking, 0)
+	case http.StateClosed:
+		atomic.StoreInt32(&c.tracking, 0)
+	}
+}
+
+func (c *errorConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if atomic.LoadInt32(&c.tracking) == 0 {
+		// Body, or hijacked
+		return n, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if atomic.LoadInt32(&c.tracking) == 0 {
+		return n, err
+	}
+	for _, b := range p[:n] {
+		switch {
+		case b == '\n':
+			c.newlines++
+		case b != '\r':
+			c.newlines = 0
+			c.head = true
+		}
+		if c.newlines >= 2 {
+			// Stop tracking until the connection is idle
+			atomic.StoreInt32(&c.tracking, 0)
+			return n, err
+		}
+	}
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() && c.head && !c.closed {
+		// Set a deadline, the write deadline for the previous request
+		// might have passed already
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+		c.writeError(http.StatusRequestTimeout, "request head not read in time")
+	}
+	return n, err
+}
+
+func (c *errorConn) Write(p []byte) (n int, err error) {
+	if atomic.LoadInt32(&c.hijacked) == 1 || !bytes.HasPrefix(p, httpPrefix) {
+		return c.Conn.Write(p)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	m := cannedResponse.FindSubmatch(p)
+	if m == nil {
+		// Response to a request
+		return c.Conn.Write(p)
+	}
+
+	code, _ := strconv.Atoi(string(m[1]))
+	// Body starts with the status code, e.g.
+	// "400 Bad Request: missing required Host header"
+	msg := strings.TrimPrefix(string(m[2]), string(m[1])+" ")
+	c.writeError(code, msg)
+	// net/http ignores write errors for these responses
+	return len(p), nil
+}
+
+// CloseWrite is used by net/http to close the connection gracefully
+// after a 431 response
+func (c *errorConn) CloseWrite() error {
+	if cw, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// writeError writes a JSON error response, and logs the error.
+// The connection is closed after the response, callers must hold the lock
+func (c *errorConn) writeError(code int, msg string) {
+	c.closed = true
+	requestID := newRequestID()
+
+	log.Error().
+		Str("request_id", requestID).
+		Int("code", code).
+		Str("remote_addr", c.RemoteAddr().String()).
+		Msg(msg)
+
+	b, err := json.MarshalIndent(share.ErrResponse{
+		Message:   msg,
+		RequestID: requestID,
+	}, "", "    ")
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(c.Conn, "HTTP/1.1 %d %s\r\n"+
+		"Content-Type: application/json; charset=UTF-8\r\n"+
+		"Content-Length: %d\r\n"+
+		"%s: %s\r\n"+
+		"Connection: close\r\n\r\n%s",
+		code, http.StatusText(code), len(b),
+		share.HeaderXRequestID, requestID, b)
+}
@@ -0,0 +1,128 @@
+package middleware_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mozey/httprouter-util/pkg/middleware"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListener(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("ok"))
+		}))
+	ts.Listener = middleware.Listener(ts.Config, ts.Listener)
+	ts.Config.MaxHeaderBytes = 1024
+	ts.Config.ReadTimeout = 200 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	// send raw request bytes and read the response
+	send := func(raw string) *http.Response {
+		conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte(raw))
+		require.NoError(t, err)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		require.NoError(t, err)
+		return resp
+	}
+	requireJSON := func(resp *http.Response, code int) share.ErrResponse {
+		defer resp.Body.Close()
+		require.Equal(t, code, resp.StatusCode)
+		require.Equal(t, "application/json; charset=UTF-8",
+			resp.Header.Get("Content-Type"))
+		body := share.ErrResponse{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		require.NotEmpty(t, body.Message)
+		require.NotEmpty(t, body.RequestID)
+		require.Equal(t, body.RequestID, resp.Header.Get(share.HeaderXRequestID))
+		return body
+	}
+
+	// Handler responses are not changed
+	resp := send("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+
+	// Header too large
+	resp = send("GET / HTTP/1.1\r\nHost: localhost\r\nX-Foo: " +
+		strings.Repeat("x", 8*1024) + "\r\n\r\n")
+	body := requireJSON(resp, http.StatusRequestHeaderFieldsTooLarge)
+	require.Equal(t, "Request Header Fields Too Large", body.Message)
+
+	// Malformed
+	resp = send("GET / HTTP/1.1\r\n\r\n")
+	body = requireJSON(resp, http.StatusBadRequest)
+	require.Contains(t, body.Message, "Host header")
+	resp = send("NOT HTTP\r\n\r\n")
+	requireJSON(resp, http.StatusBadRequest)
+
+	// Timeout reading the head
+	resp = send("GET / HTTP/1.1\r\nHost: local")
+	requireJSON(resp, http.StatusRequestTimeout)
+
+	// Idle connections are closed without a response
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	require.NoError(t, err)
+	r := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(r, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	_ = resp.Body.Close()
+	_, err = r.ReadByte()
+	require.Equal(t, io.EOF, err)
+}
+
+// TestListenerWebSocket checks that hijacked connections are not tracked,
+// a read timeout must not write a 408 response to the WebSocket
+func TestListenerWebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			// Pong wait expires after the client sent a frame
+			_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			for {
+				_, _, err = conn.ReadMessage()
+				if err != nil {
+					return
+				}
+			}
+		}))
+	ts.Listener = middleware.Listener(ts.Config, ts.Listener)
+	ts.Config.ReadTimeout = time.Second
+	ts.Start()
+	defer ts.Close()
+
+	u := "ws" + strings.TrimPrefix(ts.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(u, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("foo")))
+
+	// Connection is closed without a response
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseAbnormalClosure),
+		"%v", err)
+}
@@ -12,20 +12,29 @@ func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 
+		// Keep the logger on the context if set, e.g. by RequestID and
+		// Version, it has the request fields already
+		if log.Ctx(ctx).GetLevel() != zerolog.Disabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Pass a sub-logger by context
 		// https://github.com/rs/zerolog#pass-a-sub-logger-by-context
-		var logger zerolog.Logger
+		logCtx := log.With()
 
 		// Set request_id on logger context
 		requestID, ok :=
 			r.Context().Value(share.HeaderXRequestID).(string)
 		if ok {
-			logger = log.With().
-				Str("request_id", requestID).
-				Logger()
-		} else {
-			logger = log.With().Logger()
+			logCtx = logCtx.Str("request_id", requestID)
+		}
+		// Set api_version for versioned routes, see Version
+		version, ok := r.Context().Value(share.ContextAPIVersion).(int)
+		if ok {
+			logCtx = logCtx.Int(share.ContextAPIVersion, version)
 		}
+		logger := logCtx.Logger()
 
 		// Logger must be set on context for all requests
 		// otherwise level is set to "disabled"
@@ -4,12 +4,21 @@ import "net/http"
 
 type MaxBytesOptions struct {
 	MaxBytes int64
+	// Skipper returns true for routes that set their own limit,
+	// e.g. handler.Registry.SkipMaxBytes
+	Skipper func(r *http.Request) bool
 }
 
 // MaxBytes middleware can be used to limit POST body
 // https://stackoverflow.com/a/28292505/639133
 func MaxBytes(next http.Handler, o *MaxBytesOptions) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.Skipper != nil {
+			if o.Skipper(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
 		r.Body = http.MaxBytesReader(w, r.Body, o.MaxBytes)
 		next.ServeHTTP(w, r)
 	})
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+)
+
+// MethodNotAllowed responds with a JSON error,
+// the router sets the Allow header before calling it
+func MethodNotAllowed(h *handler.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := share.ErrResponse{
+			Message: fmt.Sprintf("method %s not allowed for path %v",
+				r.Method, r.URL.Path),
+		}
+		requestID, ok :=
+			r.Context().Value(share.HeaderXRequestID).(string)
+		if ok {
+			// Set request_id from context
+			resp.RequestID = requestID
+		}
+		h.JSON(http.StatusMethodNotAllowed, w, r, resp)
+	})
+}
+
+// GlobalOPTIONS replies to OPTIONS requests for paths without an OPTIONS route.
+// The router sets the Allow header before calling it,
+// and CORS headers are set on preflight requests by the cors middleware.
+// The cors middleware must allow all methods,
+// preflight requests get the methods allowed by the router for the path
+func GlobalOPTIONS(w http.ResponseWriter, r *http.Request) {
+	header := w.Header()
+	if header.Get("Access-Control-Allow-Origin") != "" {
+		header.Set("Access-Control-Allow-Methods", header.Get("Allow"))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mozey/httprouter-util/internal/app"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMethodNotAllowed(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	app.SetupRouter(h)
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	req := httptest.NewRequest("DELETE", "/api?token=123", nil)
+	rec := httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	require.Equal(t, "GET, OPTIONS, POST", rec.Header().Get("Allow"))
+	resp := share.ErrResponse{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Contains(t, resp.Message, "DELETE")
+	require.NotEmpty(t, resp.RequestID)
+	require.Equal(t, resp.RequestID, rec.Header().Get(share.HeaderXRequestID))
+
+	// Token is not required
+	for _, tc := range []struct {
+		method, path, allow string
+	}{
+		{"DELETE", "/api", "GET, OPTIONS, POST"},
+		{"POST", "/favicon.ico", "GET, OPTIONS"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		rec := httptest.NewRecorder()
+		h.HTTPHandler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusMethodNotAllowed, rec.Code, tc.path)
+		require.Equal(t, tc.allow, rec.Header().Get("Allow"), tc.path)
+	}
+}
+
+func TestGlobalOPTIONS(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	app.SetupRouter(h)
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	// Token is not required
+	req := httptest.NewRequest("OPTIONS", "/api", nil)
+	rec := httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "GET, OPTIONS, POST", rec.Header().Get("Allow"))
+	require.Empty(t, rec.Body.String())
+
+	// Preflight
+	req = httptest.NewRequest("OPTIONS", "/api", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "GET, OPTIONS, POST", rec.Header().Get("Allow"))
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, OPTIONS, POST",
+		rec.Header().Get("Access-Control-Allow-Methods"))
+
+	// Preflight for a method not allowed on the path
+	req = httptest.NewRequest("OPTIONS", "/api", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "GET, OPTIONS, POST",
+		rec.Header().Get("Access-Control-Allow-Methods"))
+
+	// Server-wide
+	req = httptest.NewRequest("OPTIONS", "*", nil)
+	req.URL.Path = "*"
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Contains(t, rec.Header().Get("Allow"), "GET")
+
+	// Not found
+	req = httptest.NewRequest("OPTIONS", "/does/not/exist", nil)
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
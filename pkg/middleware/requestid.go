@@ -17,13 +17,7 @@ func RequestID(next http.Handler) http.Handler {
 		requestID := r.Header.Get(share.HeaderXRequestID)
 
 		if requestID == "" {
-			// Generate new id
-			id, err := ksuid.NewRandom()
-			if err != nil {
-				requestID = err.Error()
-			} else {
-				requestID = id.String()
-			}
+			requestID = newRequestID()
 		}
 
 		// Set header
@@ -47,3 +41,12 @@ func RequestID(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// newRequestID generates a new request ID
+func newRequestID() string {
+	id, err := ksuid.NewRandom()
+	if err != nil {
+		return err.Error()
+	}
+	return id.String()
+}
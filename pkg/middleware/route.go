@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/mozey/httprouter-util/pkg/handler"
+)
+
+type RouteOptions struct {
+	Registry *handler.Registry
+}
+
+// Route looks up the route for the request once,
+// skippers using the Registry read it from the request context,
+// e.g. handler.Registry.Public and handler.Registry.SkipMaxBytes
+func Route(next http.Handler, o *RouteOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, o.Registry.WithRoute(r))
+	})
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mozey/httprouter-util/pkg/handler"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/rs/zerolog/log"
+)
+
+// APIVersion is a supported version of the API
+type APIVersion struct {
+	Version int
+	// Deprecated is the date the version was deprecated,
+	// responses have the Deprecation header if set
+	Deprecated time.Time
+	// Sunset is the date the version will be removed,
+	// responses have the Sunset header if set
+	Sunset time.Time
+}
+
+type VersionOptions struct {
+	H *handler.Handler
+	// Skipper returns true for routes that are not versioned,
+	// it is called after the path prefix is removed
+	Skipper func(r *http.Request) bool
+	// Prefixes of versioned routes, e.g. "/api", other routes are skipped.
+	// Prefixes match whole path segments, "/api" matches "/api/foo"
+	// but not "/apis". All routes are versioned if empty
+	Prefixes []string
+	// Versions that are supported
+	Versions []APIVersion
+	// Default is used if the request doesn't specify a version,
+	// e.g. clients that were built before versioning
+	Default int
+	// MediaType for the Accept header version parameter, e.g.
+	// "Accept: application/vnd.httprouter-util+json; version=2"
+	MediaType string
+}
+
+// versioned returns true if the path has one of the prefixes
+func versioned(path string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Version middleware sets the API version on the request context.
+// The version is selected by a path prefix, e.g. "/v2/api",
+// the API-Version header, or the version parameter of the Accept header.
+// The path prefix is removed before routing.
+// Unsupported versions are rejected with a 400 response
+func Version(next http.Handler, o *VersionOptions) http.Handler {
+	versions := make(map[int]APIVersion, len(o.Versions))
+	for _, v := range o.Versions {
+		versions[v.Version] = v
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		version, path, ok := pathVersion(r.URL.Path)
+		stripped := r
+		if ok {
+			// Strip the prefix
+			stripped = r.Clone(ctx)
+			stripped.URL.Path = path
+			stripped.URL.RawPath = ""
+		}
+
+		if !versioned(stripped.URL.Path, o.Prefixes) ||
+			(o.Skipper != nil && o.Skipper(stripped)) {
+			// Versioned paths for routes that are not versioned are not found.
+			// Call the next handler
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = stripped
+
+		var err error
+		if !ok {
+			version, ok, err = headerVersion(r, o.MediaType)
+		}
+		if err == nil && !ok {
+			version = o.Default
+		}
+		v, supported := versions[version]
+		if err != nil || !supported {
+			resp := share.ErrResponse{
+				Message: "unsupported API version",
+			}
+			requestID, ok :=
+				r.Context().Value(share.HeaderXRequestID).(string)
+			if ok {
+				// Set request_id from context
+				resp.RequestID = requestID
+			}
+			o.H.JSON(http.StatusBadRequest, w, r, resp)
+			return
+		}
+
+		// Response depends on the version headers
+		handler.Vary(w.Header(), share.HeaderAPIVersion)
+		handler.Vary(w.Header(), "Accept")
+		w.Header().Set(share.HeaderAPIVersion, strconv.Itoa(version))
+		if !v.Deprecated.IsZero() {
+			// https://www.rfc-editor.org/rfc/rfc9745
+			w.Header().Set("Deprecation",
+				fmt.Sprintf("@%d", v.Deprecated.Unix()))
+		}
+		if !v.Sunset.IsZero() {
+			// https://www.rfc-editor.org/rfc/rfc8594
+			w.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+		}
+
+		ctx = context.WithValue(ctx, share.ContextAPIVersion, version)
+		// Used by the Logger middleware
+		logger := log.Ctx(ctx).With().
+			Int(share.ContextAPIVersion, version).
+			Logger()
+		ctx = logger.WithContext(ctx)
+
+		// Call the next handler
+		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// pathVersion parses the version prefix, e.g. "/v2/api" is version 2,
+// the path without the prefix is "/api"
+func pathVersion(p string) (version int, path string, ok bool) {
+	if !strings.HasPrefix(p, "/v") {
+		return 0, p, false
+	}
+	segment := p[2:]
+	i := strings.Index(segment, "/")
+	if i < 0 {
+		i = len(segment)
+	}
+	version, err := strconv.Atoi(segment[:i])
+	if err != nil || version < 1 {
+		return 0, p, false
+	}
+	path = segment[i:]
+	if path == "" {
+		path = "/"
+	}
+	return version, path, true
+}
+
+// headerVersion parses the API-Version header,
+// or the version parameter of the Accept header
+func headerVersion(r *http.Request, mediaType string) (
+	version int, ok bool, err error) {
+
+	if s := r.Header.Get(share.HeaderAPIVersion); s != "" {
+		version, err = strconv.Atoi(strings.TrimPrefix(s, "v"))
+		return version, true, err
+	}
+	if mediaType == "" {
+		return 0, false, nil
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		t, params, err := mime.ParseMediaType(accept)
+		if err != nil || t != mediaType {
+			continue
+		}
+		if s, ok := params["version"]; ok {
+			version, err = strconv.Atoi(s)
+			return version, true, err
+		}
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,175 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mozey/httprouter-util/internal/app"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/middleware"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersion(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v[0])
+		}
+		rec := httptest.NewRecorder()
+		h.HTTPHandler.ServeHTTP(rec, req)
+		return rec
+	}
+	version := func(rec *httptest.ResponseRecorder) int {
+		resp := share.WelcomeResponse{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		return resp.Version
+	}
+
+	// Default version
+	rec := get("/api?token=123", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "1", rec.Header().Get(share.HeaderAPIVersion))
+	require.Equal(t, 0, version(rec))
+	require.Empty(t, rec.Header().Get("Deprecation"))
+	require.Empty(t, rec.Header().Get("Sunset"))
+
+	// Path prefix
+	rec = get("/v2/api?token=123", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "2", rec.Header().Get(share.HeaderAPIVersion))
+	require.Equal(t, 2, version(rec))
+	require.Empty(t, rec.Header().Get("Deprecation"))
+	require.Empty(t, rec.Header().Get("Sunset"))
+	rec = get("/v1/api?token=123", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 0, version(rec))
+
+	// Header
+	rec = get("/api?token=123", http.Header{share.HeaderAPIVersion: {"2"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 2, version(rec))
+
+	// Accept header
+	rec = get("/api?token=123", http.Header{"Accept": {
+		"text/html, application/vnd.httprouter-util+json; version=2"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 2, version(rec))
+
+	// Path prefix takes precedence
+	rec = get("/v1/api?token=123", http.Header{share.HeaderAPIVersion: {"2"}})
+	require.Equal(t, "1", rec.Header().Get(share.HeaderAPIVersion))
+
+	// Unsupported
+	rec = get("/v3/api?token=123", nil)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = get("/api?token=123", http.Header{share.HeaderAPIVersion: {"x"}})
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Routes that are not versioned
+	rec = get("/favicon.ico", nil)
+	require.Empty(t, rec.Header().Get(share.HeaderAPIVersion))
+	rec = get("/v2/favicon.ico?token=123", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	// Prefixes match whole path segments
+	rec = get("/apis?token=123", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Empty(t, rec.Header().Get(share.HeaderAPIVersion))
+	rec = get("/client/version?token=123", nil)
+	require.Equal(t, "1", rec.Header().Get(share.HeaderAPIVersion))
+}
+
+func TestVersionDeprecated(t *testing.T) {
+	versions := app.APIVersions
+	defer func() { app.APIVersions = versions }()
+	app.APIVersions = []middleware.APIVersion{
+		{
+			Version:    1,
+			Deprecated: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+			Sunset:     time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{Version: 2},
+	}
+
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	req := httptest.NewRequest("GET", "/api?token=123", nil)
+	rec := httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Regexp(t, `^@\d+$`, rec.Header().Get("Deprecation"))
+	require.Equal(t, "Thu, 01 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+
+	req = httptest.NewRequest("GET", "/v2/api?token=123", nil)
+	rec = httptest.NewRecorder()
+	h.HTTPHandler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get("Deprecation"))
+	require.Empty(t, rec.Header().Get("Sunset"))
+}
+
+// TestVersionLogger checks the api_version field on log lines
+func TestVersionLogger(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	buf := &bytes.Buffer{}
+	logger := log.Logger
+	log.Logger = zerolog.New(buf)
+	defer func() {
+		log.Logger = logger
+	}()
+
+	lines := func(target string, header http.Header) []map[string]interface{} {
+		buf.Reset()
+		req := httptest.NewRequest("GET", target, nil)
+		for k, v := range header {
+			req.Header.Set(k, v[0])
+		}
+		rec := httptest.NewRecorder()
+		h.HTTPHandler.ServeHTTP(rec, req)
+		requestID := rec.Header().Get(share.HeaderXRequestID)
+		require.NotEmpty(t, requestID)
+		var lines []map[string]interface{}
+		for _, b := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			line := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(b, &line))
+			require.Equal(t, requestID, line["request_id"])
+			lines = append(lines, line)
+		}
+		require.NotEmpty(t, lines)
+		return lines
+	}
+
+	for _, line := range lines("/v2/api?token=123", nil) {
+		require.Equal(t, 2.0, line[share.ContextAPIVersion])
+	}
+	// Unsupported version is logged with the request ID
+	for _, line := range lines("/api?token=123",
+		http.Header{share.HeaderAPIVersion: {"3"}}) {
+		require.Equal(t, 400.0, line["code"])
+	}
+}
@@ -0,0 +1,58 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mozey/httprouter-util/internal/app"
+	"github.com/mozey/httprouter-util/pkg/config"
+	"github.com/mozey/httprouter-util/pkg/share"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWebSocket upgrades through the middleware chain,
+// see SetupMiddleware in internal/app/handler.go
+func TestWebSocket(t *testing.T) {
+	conf, err := config.LoadFile("dev")
+	require.NoError(t, err)
+	h := app.NewHandler(conf)
+	h.Routes()
+	app.SetupMiddleware(h)
+	defer h.Cleanup()
+
+	srv := httptest.NewServer(h.HTTPHandler)
+	defer srv.Close()
+	u := "ws" + strings.TrimPrefix(srv.URL, "http") + "/echo"
+
+	// Auth middleware applies
+	header := http.Header{}
+	header.Set("Accept-Encoding", "gzip")
+	_, resp, err := websocket.DefaultDialer.Dial(u, header)
+	require.Error(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(u+"?token=123", header)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NotEmpty(t, resp.Header.Get(share.HeaderXRequestID))
+
+	err = conn.WriteMessage(websocket.TextMessage, []byte("foo"))
+	require.NoError(t, err)
+	_, b, err := conn.ReadMessage()
+	require.NoError(t, err)
+	require.Equal(t, "foo", string(b))
+
+	// Close frame is sent on shutdown
+	h.Shutdown()
+	_, _, err = conn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, h.Wait(ctx))
+}
@@ -0,0 +1,280 @@
+// Package openapi defines the subset of OpenAPI 3.1 used to document routes,
+// https://spec.openapis.org/oas/v3.1.0
+// Parse also accepts 3.0 documents, e.g. for the mock server
+// Schemas are JSON Schema, see pkg/schema
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/pkg/errors"
+)
+
+// Version of the specification
+const Version = "3.1.0"
+
+// RefPrefix is used to reference schemas in Components
+const RefPrefix = "#/components/schemas/"
+
+// Document is the root object
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components *Components           `json:"components,omitempty"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lowercase HTTP methods to operations
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	// Security overrides the document security if not nil,
+	// an empty list means no security
+	Security *[]SecurityRequirement `json:"security,omitempty"`
+}
+
+// Parameter locations
+const (
+	InPath  = "path"
+	InQuery = "query"
+)
+
+type Parameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *schema.Schema `json:"schema,omitempty"`
+	Example     interface{}    `json:"example,omitempty"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema   *schema.Schema     `json:"schema,omitempty"`
+	Example  interface{}        `json:"example,omitempty"`
+	Examples map[string]Example `json:"examples,omitempty"`
+}
+
+type Example struct {
+	Summary string      `json:"summary,omitempty"`
+	Value   interface{} `json:"value,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*schema.Schema  `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme, e.g. an API key in the query string
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Scheme      string `json:"scheme,omitempty"`
+}
+
+// SecurityRequirement maps scheme names to scopes
+type SecurityRequirement map[string][]string
+
+// Ref returns a schema that references the named component
+func Ref(name string) *schema.Schema {
+	return &schema.Schema{Ref: RefPrefix + name}
+}
+
+// Path converts a router pattern to an OpenAPI path,
+// e.g. "/hello/:name" becomes "/hello/{name}"
+func Path(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Pattern converts an OpenAPI path to a router pattern,
+// e.g. "/hello/{name}" becomes "/hello/:name"
+func Pattern(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			segments[i] = ":" + s[1:len(s)-1]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// defsPrefix is used by LocalRefs
+const defsPrefix = "#/$defs/"
+
+// LocalRefs rewrites references to components, so schemas in the document
+// can be used as sub-schemas of Root, e.g. for validation.
+// The document is modified, call it once after Load
+func (doc *Document) LocalRefs() {
+	doc.walk(func(s *schema.Schema) {
+		if strings.HasPrefix(s.Ref, RefPrefix) {
+			s.Ref = defsPrefix + strings.TrimPrefix(s.Ref, RefPrefix)
+		}
+	})
+}
+
+// Root returns a root schema for s, that resolves references to components.
+// See LocalRefs
+func (doc *Document) Root(s *schema.Schema) *schema.Schema {
+	root := &schema.Schema{AllOf: []*schema.Schema{s}}
+	if doc.Components != nil {
+		root.Defs = doc.Components.Schemas
+	}
+	return root
+}
+
+// walk calls fn for every schema in the document
+func (doc *Document) walk(fn func(s *schema.Schema)) {
+	if doc.Components != nil {
+		for _, s := range doc.Components.Schemas {
+			s.Walk(fn)
+		}
+	}
+	for _, item := range doc.Paths {
+		for _, op := range item {
+			for _, p := range op.Parameters {
+				p.Schema.Walk(fn)
+			}
+			if op.RequestBody != nil {
+				for _, mt := range op.RequestBody.Content {
+					mt.Schema.Walk(fn)
+				}
+			}
+			for _, resp := range op.Responses {
+				for _, mt := range resp.Content {
+					mt.Schema.Walk(fn)
+				}
+			}
+		}
+	}
+}
+
+// Parse a document from JSON.
+// Versions 3.0 and 3.1 are supported, 3.0 schema keywords that differ from
+// JSON Schema are converted, see convertSchema30
+func Parse(b []byte) (doc *Document, err error) {
+	version := struct {
+		OpenAPI string `json:"openapi"`
+	}{}
+	err = json.Unmarshal(b, &version)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch {
+	case strings.HasPrefix(version.OpenAPI, "3.1."):
+	case strings.HasPrefix(version.OpenAPI, "3.0."):
+		b, err = convert30(b)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf(
+			"unsupported openapi version %q", version.OpenAPI)
+	}
+	doc = &Document{}
+	err = json.Unmarshal(b, doc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return doc, nil
+}
+
+// convert30 converts the schemas in a 3.0 document to JSON Schema
+func convert30(b []byte) ([]byte, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err := d.Decode(&v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	convertSchema30(v)
+	b, err = json.Marshal(v)
+	return b, errors.WithStack(err)
+}
+
+// convertSchema30 walks the decoded document,
+// 3.0 does not allow boolean schemas, so boolean values for these keywords
+// are always the 3.0 form:
+//   - "nullable": true adds "null" to the type
+//   - "exclusiveMinimum": true replaces "minimum", same for the maximum
+func convertSchema30(v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			convertSchema30(item)
+		}
+
+	case map[string]interface{}:
+		if nullable, ok := v["nullable"].(bool); ok {
+			delete(v, "nullable")
+			typ, ok := v["type"].(string)
+			if ok && nullable {
+				v["type"] = []interface{}{typ, "null"}
+			}
+		}
+		for exclusive, inclusive := range map[string]string{
+			"exclusiveMinimum": "minimum",
+			"exclusiveMaximum": "maximum",
+		} {
+			if b, ok := v[exclusive].(bool); ok {
+				delete(v, exclusive)
+				if b && v[inclusive] != nil {
+					v[exclusive] = v[inclusive]
+					delete(v, inclusive)
+				}
+			}
+		}
+		for _, value := range v {
+			convertSchema30(value)
+		}
+	}
+}
+
+// Load a document from the JSON file at path
+func Load(path string) (doc *Document, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	doc, err = Parse(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "openapi %s", path)
+	}
+	return doc, nil
+}
@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// maxExampleDepth limits nesting of generated examples, e.g. recursive refs
+const maxExampleDepth = 8
+
+// Example returns a value that is valid against the schema, for mocks.
+// Annotations are used if set, i.e. examples, default, const and enum.
+// Otherwise a value is generated from the type and constraints.
+// Generated values are deterministic
+func (s *Schema) Example() interface{} {
+	return example(s, s, 0)
+}
+
+func example(s, root *Schema, depth int) interface{} {
+	for i := 0; s.Ref != ""; i++ {
+		resolved, err := s.resolve(root)
+		if err != nil || i > 32 {
+			return nil
+		}
+		s = resolved
+	}
+	if s.boolean != nil || depth > 2*maxExampleDepth {
+		// Hard limit for recursive refs that don't nest objects or arrays
+		return nil
+	}
+
+	switch {
+	case len(s.Examples) > 0:
+		return s.Examples[0]
+	case s.Default != nil:
+		return s.Default
+	case s.Const != nil:
+		return constValue(s.Const)
+	case len(s.Enum) > 0:
+		return s.Enum[0]
+	case len(s.AllOf) > 0:
+		return exampleAllOf(s, root, depth)
+	case len(s.OneOf) > 0:
+		return example(s.OneOf[0], root, depth+1)
+	case len(s.AnyOf) > 0:
+		return example(s.AnyOf[0], root, depth+1)
+	}
+
+	typ := ""
+	for _, t := range s.Type {
+		if t != "null" {
+			typ = t
+			break
+		}
+	}
+	if typ == "" && len(s.Properties) > 0 {
+		typ = "object"
+	}
+
+	if (typ == "object" || typ == "array") && depth > maxExampleDepth {
+		return nil
+	}
+	switch typ {
+	case "object":
+		return exampleProperties(s, root, depth, make(map[string]interface{}))
+	case "array":
+		n := 1
+		if s.MinItems != nil && *s.MinItems > n {
+			n = *s.MinItems
+		}
+		if s.MaxItems != nil && *s.MaxItems < n {
+			n = *s.MaxItems
+		}
+		a := make([]interface{}, 0, n)
+		for i := 0; i < n && s.Items != nil; i++ {
+			a = append(a, example(s.Items, root, depth+1))
+		}
+		return a
+	case "string":
+		return exampleString(s)
+	case "integer", "number":
+		return exampleNumber(s, typ)
+	case "boolean":
+		return true
+	}
+	return nil
+}
+
+// exampleAllOf merges the properties of each object
+func exampleAllOf(s, root *Schema, depth int) interface{} {
+	m := make(map[string]interface{})
+	var last interface{}
+	for _, sub := range s.AllOf {
+		last = example(sub, root, depth+1)
+		if sm, ok := last.(map[string]interface{}); ok {
+			for k, v := range sm {
+				m[k] = v
+			}
+		}
+	}
+	if len(m) == 0 {
+		return last
+	}
+	return exampleProperties(s, root, depth, m)
+}
+
+// exampleProperties sets a value for each property in m.
+// Optional properties without a value are omitted, e.g. recursive refs
+func exampleProperties(s, root *Schema, depth int,
+	m map[string]interface{}) map[string]interface{} {
+
+	required := make(map[string]bool, len(s.Required))
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	for name, p := range s.Properties {
+		v := example(p, root, depth+1)
+		if v == nil && !required[name] {
+			continue
+		}
+		m[name] = v
+	}
+	return m
+}
+
+func exampleString(s *Schema) string {
+	str := "string"
+	switch s.Format {
+	case "date-time":
+		str = time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Format(time.RFC3339)
+	case "date":
+		str = "2006-01-02"
+	case "email":
+		str = "user@example.com"
+	case "uri":
+		str = "https://example.com"
+	case "uuid":
+		str = "00000000-0000-0000-0000-000000000000"
+	}
+	if s.MinLength != nil && len(str) < *s.MinLength {
+		str += strings.Repeat("x", *s.MinLength-len(str))
+	}
+	if s.MaxLength != nil && len(str) > *s.MaxLength {
+		str = str[:*s.MaxLength]
+	}
+	return str
+}
+
+func exampleNumber(s *Schema, typ string) float64 {
+	n := 0.0
+	switch {
+	case s.Minimum != nil:
+		n = *s.Minimum
+	case s.ExclusiveMinimum != nil:
+		n = *s.ExclusiveMinimum + 1
+	case s.Maximum != nil && *s.Maximum < n:
+		n = *s.Maximum
+	case s.ExclusiveMaximum != nil && *s.ExclusiveMaximum <= n:
+		n = *s.ExclusiveMaximum - 1
+	}
+	if s.MultipleOf != nil && *s.MultipleOf > 0 {
+		m := *s.MultipleOf
+		n = math.Ceil(n/m) * m
+	}
+	if typ == "integer" {
+		n = math.Ceil(n)
+	}
+	return n
+}
+
+// Walk calls fn for s and every sub-schema, depth first
+func (s *Schema) Walk(fn func(s *Schema)) {
+	if s == nil {
+		return
+	}
+	fn(s)
+	for _, m := range []map[string]*Schema{s.Definitions, s.Defs, s.Properties} {
+		for _, sub := range m {
+			sub.Walk(fn)
+		}
+	}
+	for _, a := range [][]*Schema{s.AllOf, s.AnyOf, s.OneOf} {
+		for _, sub := range a {
+			sub.Walk(fn)
+		}
+	}
+	s.AdditionalProperties.Walk(fn)
+	s.Items.Walk(fn)
+	s.Not.Walk(fn)
+}
@@ -0,0 +1,44 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExample(t *testing.T) {
+	s, err := schema.Parse([]byte(`{
+		"type": "object",
+		"properties": {
+			"id": {"type": "string", "format": "uuid"},
+			"color": {"enum": ["red", "green"]},
+			"count": {"type": "integer", "minimum": 1.5, "multipleOf": 2},
+			"items": {
+				"type": "array",
+				"minItems": 2,
+				"items": {"$ref": "#/definitions/item"}
+			}
+		},
+		"definitions": {
+			"item": {
+				"type": "object",
+				"properties": {
+					"name": {"type": "string", "maxLength": 3},
+					"next": {"$ref": "#/definitions/item"}
+				}
+			}
+		}
+	}`))
+	require.NoError(t, err)
+
+	v := s.Example()
+	require.Empty(t, s.Validate(v))
+	m := v.(map[string]interface{})
+	require.Equal(t, "00000000-0000-0000-0000-000000000000", m["id"])
+	require.Equal(t, "red", m["color"])
+	require.Equal(t, 2.0, m["count"])
+	items := m["items"].([]interface{})
+	require.Len(t, items, 2)
+	require.Equal(t, "str", items[0].(map[string]interface{})["name"])
+}
@@ -0,0 +1,231 @@
+// Package schema implements a subset of JSON Schema (draft 7)
+// for validating request and response bodies.
+//
+// Supported keywords, the fields of the Schema struct:
+//
+//   - Any type: type, enum, const, allOf, anyOf, oneOf, not,
+//     and the boolean schemas true and false
+//   - Objects: properties, required, additionalProperties
+//   - Arrays: items (a single schema), minItems, maxItems, uniqueItems
+//   - Strings: minLength, maxLength, pattern, and format for
+//     date-time, date, email, uri and uuid, other formats are not checked
+//   - Numbers: minimum, maximum, exclusiveMinimum, exclusiveMaximum
+//     (draft 6 numbers, not draft 4 booleans), multipleOf
+//   - References: $ref to "#", "#/definitions/..." or "#/$defs/..."
+//   - Annotations: $id, title, description, default, examples
+//
+// Other keywords are ignored when validating, e.g. patternProperties,
+// propertyNames, minProperties, maxProperties, dependencies, contains,
+// tuple items, if, then, else, and remote references
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Schema is a JSON Schema document or sub-schema
+type Schema struct {
+	ID          string             `json:"$id,omitempty"`
+	Ref         string             `json:"$ref,omitempty"`
+	Title       string             `json:"title,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Definitions map[string]*Schema `json:"definitions,omitempty"`
+	Defs        map[string]*Schema `json:"$defs,omitempty"`
+
+	Type Types         `json:"type,omitempty"`
+	Enum []interface{} `json:"enum,omitempty"`
+	// Const is not set if nil, use Null for "const": null
+	Const  interface{} `json:"const,omitempty"`
+	Format string      `json:"format,omitempty"`
+
+	// Objects
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+
+	// Arrays
+	Items       *Schema `json:"items,omitempty"`
+	MinItems    *int    `json:"minItems,omitempty"`
+	MaxItems    *int    `json:"maxItems,omitempty"`
+	UniqueItems bool    `json:"uniqueItems,omitempty"`
+
+	// Strings
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+
+	// Numbers
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *float64 `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *float64 `json:"exclusiveMaximum,omitempty"`
+	MultipleOf       *float64 `json:"multipleOf,omitempty"`
+
+	// Composition
+	AllOf []*Schema `json:"allOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+	OneOf []*Schema `json:"oneOf,omitempty"`
+	Not   *Schema   `json:"not,omitempty"`
+
+	// Annotations, not used for validation
+	Default  interface{}   `json:"default,omitempty"`
+	Examples []interface{} `json:"examples,omitempty"`
+
+	// boolean is set for the schemas true and false
+	boolean *bool
+}
+
+// Types is the "type" keyword, a string or list of strings
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = Types{s}
+		return nil
+	}
+	var a []string
+	if err := json.Unmarshal(b, &a); err != nil {
+		return errors.WithStack(err)
+	}
+	*t = a
+	return nil
+}
+
+// Has returns true if t is empty or contains typ
+func (t Types) Has(typ string) bool {
+	if len(t) == 0 {
+		return true
+	}
+	for _, v := range t {
+		if v == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// null is the JSON null value
+type null struct{}
+
+func (null) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// Null is the value of Const for "const": null,
+// a nil Const means the keyword is not set
+var Null interface{} = null{}
+
+// Bool returns the schema true, that allows anything,
+// or false, that allows nothing
+func Bool(b bool) *Schema {
+	return &Schema{boolean: &b}
+}
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.boolean != nil {
+		return json.Marshal(*s.boolean)
+	}
+	type schema Schema
+	return json.Marshal((*schema)(s))
+}
+
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	var boolean bool
+	if err := json.Unmarshal(b, &boolean); err == nil {
+		*s = Schema{boolean: &boolean}
+		return nil
+	}
+	type schema Schema
+	err := json.Unmarshal(b, (*schema)(s))
+	if err != nil {
+		return err
+	}
+	if s.Const == nil && bytes.Contains(b, []byte(`"const"`)) {
+		// Unmarshal sets Const to nil for both null and a missing keyword
+		var keywords map[string]json.RawMessage
+		err = json.Unmarshal(b, &keywords)
+		if err != nil {
+			return err
+		}
+		if c, ok := keywords["const"]; ok && string(c) == "null" {
+			s.Const = Null
+		}
+	}
+	return nil
+}
+
+// Parse a schema from JSON
+func Parse(b []byte) (s *Schema, err error) {
+	s = &Schema{}
+	err = json.Unmarshal(b, s)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s, nil
+}
+
+// Load a schema from the JSON file at path
+func Load(path string) (s *Schema, err error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, err = Parse(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "schema %s", path)
+	}
+	return s, nil
+}
+
+// LoadFS is like Load, the schema is read from fsys
+func LoadFS(fsys fs.FS, name string) (s *Schema, err error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, err = Parse(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "schema %s", name)
+	}
+	return s, nil
+}
+
+// resolve a local reference relative to the root schema
+func (s *Schema) resolve(root *Schema) (*Schema, error) {
+	if s.Ref == "" {
+		return s, nil
+	}
+	ref := s.Ref
+	var defs map[string]*Schema
+	switch {
+	case strings.HasPrefix(ref, "#/definitions/"):
+		defs = root.Definitions
+		ref = strings.TrimPrefix(ref, "#/definitions/")
+	case strings.HasPrefix(ref, "#/$defs/"):
+		defs = root.Defs
+		ref = strings.TrimPrefix(ref, "#/$defs/")
+	case ref == "#":
+		return root, nil
+	default:
+		return nil, errors.Errorf("unsupported $ref %s", s.Ref)
+	}
+	resolved, ok := defs[ref]
+	if !ok {
+		return nil, errors.Errorf("unresolved $ref %s", s.Ref)
+	}
+	return resolved, nil
+}
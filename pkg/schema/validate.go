@@ -0,0 +1,338 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Root is the path for errors on the document itself
+const Root = "(root)"
+
+// Error describes a single validation failure,
+// Path is the JSON path, e.g. "items[0].name"
+type Error struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%s %s", e.Path, e.Message)
+}
+
+// ValidateJSON decodes b and validates it against the schema
+func (s *Schema) ValidateJSON(b []byte) (errs []Error, err error) {
+	var v interface{}
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s.Validate(v), nil
+}
+
+// Validate v against the schema, v must be the result of
+// decoding JSON into an interface{}, e.g. map[string]interface{}.
+// Returns every error found, or nil if v is valid
+func (s *Schema) Validate(v interface{}) []Error {
+	vr := validator{root: s}
+	vr.validate(s, v, "")
+	return vr.errs
+}
+
+type validator struct {
+	root *Schema
+	errs []Error
+}
+
+func (vr *validator) add(path, format string, a ...interface{}) {
+	if path == "" {
+		path = Root
+	}
+	vr.errs = append(vr.errs, Error{
+		Path:    path,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+// valid returns true if v is valid against s,
+// without recording errors
+func (vr *validator) valid(s *Schema, v interface{}) bool {
+	sub := validator{root: vr.root}
+	sub.validate(s, v, "")
+	return len(sub.errs) == 0
+}
+
+func (vr *validator) validate(s *Schema, v interface{}, path string) {
+	// Follow references, limit depth to avoid cycles
+	for i := 0; s.Ref != ""; i++ {
+		if i > 32 {
+			vr.add(path, "has too many nested references")
+			return
+		}
+		var err error
+		s, err = s.resolve(vr.root)
+		if err != nil {
+			vr.add(path, "%s", err.Error())
+			return
+		}
+	}
+
+	if s.boolean != nil {
+		if !*s.boolean {
+			vr.add(path, "is not allowed")
+		}
+		return
+	}
+
+	typ := typeOf(v)
+	// Integers are also numbers
+	if !s.Type.Has(typ) && !(typ == "integer" && s.Type.Has("number")) {
+		vr.add(path, "must be of type %s", strings.Join(s.Type, " or "))
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			vr.add(path, "must be one of %s", joinJSON(s.Enum))
+		}
+	}
+	if s.Const != nil && !reflect.DeepEqual(constValue(s.Const), v) {
+		vr.add(path, "must be %s", joinJSON([]interface{}{s.Const}))
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		vr.validateObject(s, t, path)
+	case []interface{}:
+		vr.validateArray(s, t, path)
+	case string:
+		vr.validateString(s, t, path)
+	case float64:
+		vr.validateNumber(s, t, path)
+	}
+
+	for _, sub := range s.AllOf {
+		vr.validate(sub, v, path)
+	}
+	if len(s.AnyOf) > 0 {
+		found := false
+		for _, sub := range s.AnyOf {
+			if vr.valid(sub, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			vr.add(path, "must match at least one schema in anyOf")
+		}
+	}
+	if len(s.OneOf) > 0 {
+		count := 0
+		for _, sub := range s.OneOf {
+			if vr.valid(sub, v) {
+				count++
+			}
+		}
+		if count != 1 {
+			vr.add(path, "must match exactly one schema in oneOf, matched %d", count)
+		}
+	}
+	if s.Not != nil && vr.valid(s.Not, v) {
+		vr.add(path, "must not match the schema in not")
+	}
+}
+
+func (vr *validator) validateObject(
+	s *Schema, m map[string]interface{}, path string) {
+
+	for _, name := range s.Required {
+		if _, ok := m[name]; !ok {
+			vr.add(join(path, name), "is required")
+		}
+	}
+
+	// Sort keys so errors are listed in a consistent order
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		prop, ok := s.Properties[k]
+		if ok {
+			vr.validate(prop, m[k], join(path, k))
+		} else if s.AdditionalProperties != nil {
+			vr.validate(s.AdditionalProperties, m[k], join(path, k))
+		}
+	}
+}
+
+func (vr *validator) validateArray(
+	s *Schema, a []interface{}, path string) {
+
+	if s.MinItems != nil && len(a) < *s.MinItems {
+		vr.add(path, "must have at least %d items", *s.MinItems)
+	}
+	if s.MaxItems != nil && len(a) > *s.MaxItems {
+		vr.add(path, "must have at most %d items", *s.MaxItems)
+	}
+	if s.UniqueItems {
+		for i := 0; i < len(a); i++ {
+			for j := i + 1; j < len(a); j++ {
+				if reflect.DeepEqual(a[i], a[j]) {
+					vr.add(path, "must have unique items, %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+	if s.Items != nil {
+		for i, item := range a {
+			vr.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (vr *validator) validateString(s *Schema, str string, path string) {
+	n := len([]rune(str))
+	if s.MinLength != nil && n < *s.MinLength {
+		vr.add(path, "must have at least %d characters", *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		vr.add(path, "must have at most %d characters", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		re, err := compile(s.Pattern)
+		if err != nil {
+			vr.add(path, "has invalid pattern %s", s.Pattern)
+		} else if !re.MatchString(str) {
+			vr.add(path, "must match pattern %s", s.Pattern)
+		}
+	}
+	if s.Format != "" && !validFormat(s.Format, str) {
+		vr.add(path, "must be a valid %s", s.Format)
+	}
+}
+
+func (vr *validator) validateNumber(s *Schema, f float64, path string) {
+	if s.Minimum != nil && f < *s.Minimum {
+		vr.add(path, "must be at least %v", *s.Minimum)
+	}
+	if s.Maximum != nil && f > *s.Maximum {
+		vr.add(path, "must be at most %v", *s.Maximum)
+	}
+	if s.ExclusiveMinimum != nil && f <= *s.ExclusiveMinimum {
+		vr.add(path, "must be greater than %v", *s.ExclusiveMinimum)
+	}
+	if s.ExclusiveMaximum != nil && f >= *s.ExclusiveMaximum {
+		vr.add(path, "must be less than %v", *s.ExclusiveMaximum)
+	}
+	if s.MultipleOf != nil && *s.MultipleOf != 0 {
+		q := f / *s.MultipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			vr.add(path, "must be a multiple of %v", *s.MultipleOf)
+		}
+	}
+}
+
+// typeOf returns the JSON Schema type name for v
+func typeOf(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float64:
+		if t == math.Trunc(t) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func joinJSON(a []interface{}) string {
+	s := make([]string, len(a))
+	for i, v := range a {
+		b, _ := json.Marshal(v)
+		s[i] = string(b)
+	}
+	return strings.Join(s, ", ")
+}
+
+// patterns caches compiled regexps by pattern
+var patterns sync.Map
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	cached, ok := patterns.Load(pattern)
+	if ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+var reUUID = regexp.MustCompile(
+	`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validFormat checks common formats, unknown formats are ignored
+func validFormat(format, s string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	case "email":
+		_, err := mail.ParseAddress(s)
+		return err == nil
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.Scheme != ""
+	case "uuid":
+		return reUUID.MatchString(s)
+	}
+	return true
+}
+
+// constValue returns nil for Null
+func constValue(c interface{}) interface{} {
+	if c == Null {
+		return nil
+	}
+	return c
+}
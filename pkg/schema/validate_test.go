@@ -0,0 +1,104 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/mozey/httprouter-util/pkg/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	s, err := schema.Parse([]byte(`{
+		"type": "object",
+		"required": ["id", "items"],
+		"additionalProperties": false,
+		"properties": {
+			"id": {"type": "string", "format": "uuid"},
+			"color": {"enum": ["red", "green"]},
+			"items": {
+				"type": "array",
+				"minItems": 1,
+				"items": {"$ref": "#/definitions/item"}
+			}
+		},
+		"definitions": {
+			"item": {
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string", "pattern": "^[a-z]+$"},
+					"qty": {"type": "integer", "minimum": 1}
+				}
+			}
+		}
+	}`))
+	require.NoError(t, err)
+
+	// Valid
+	errs, err := s.ValidateJSON([]byte(`{
+		"id": "0b6e5e0e-6a3f-4b8e-9d3c-6d8f2c4d1a2b",
+		"color": "red",
+		"items": [{"name": "foo", "qty": 2}]
+	}`))
+	require.NoError(t, err)
+	require.Empty(t, errs)
+
+	// Invalid
+	errs, err = s.ValidateJSON([]byte(`{
+		"id": "123",
+		"color": "blue",
+		"items": [{"name": "Foo", "qty": 1.5}, {}],
+		"extra": true
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, []schema.Error{
+		{Path: "color", Message: `must be one of "red", "green"`},
+		{Path: "extra", Message: "is not allowed"},
+		{Path: "id", Message: "must be a valid uuid"},
+		{Path: "items[0].name", Message: "must match pattern ^[a-z]+$"},
+		{Path: "items[0].qty", Message: "must be of type integer"},
+		{Path: "items[1].name", Message: "is required"},
+	}, errs)
+
+	// Root
+	errs = s.Validate(nil)
+	require.Equal(t, []schema.Error{
+		{Path: schema.Root, Message: "must be of type object"},
+	}, errs)
+
+	// Round trip boolean schemas
+	b, err := s.AdditionalProperties.MarshalJSON()
+	require.NoError(t, err)
+	require.Equal(t, "false", string(b))
+}
+
+func TestConst(t *testing.T) {
+	s, err := schema.Parse([]byte(`{
+		"properties": {
+			"a": {"const": null},
+			"b": {"const": 1},
+			"c": {"type": "string"}
+		}
+	}`))
+	require.NoError(t, err)
+	require.Equal(t, schema.Null, s.Properties["a"].Const)
+	require.Nil(t, s.Properties["c"].Const)
+
+	errs, err := s.ValidateJSON([]byte(`{"a": null, "b": 1, "c": "x"}`))
+	require.NoError(t, err)
+	require.Empty(t, errs)
+	errs, err = s.ValidateJSON([]byte(`{"a": 0, "b": 2}`))
+	require.NoError(t, err)
+	require.Equal(t, []schema.Error{
+		{Path: "a", Message: "must be null"},
+		{Path: "b", Message: "must be 1"},
+	}, errs)
+
+	// Round trip
+	b, err := s.Properties["a"].MarshalJSON()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"const": null}`, string(b))
+	b, err = s.Properties["c"].MarshalJSON()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"type": "string"}`, string(b))
+}
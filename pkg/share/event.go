@@ -0,0 +1,10 @@
+package share
+
+// EventClientVersion is published with ClientVersion data
+// when a new client is built
+const EventClientVersion = "client_version"
+
+// EventReset is sent to clients that reconnect after the events they
+// missed are no longer buffered, the data is their Last-Event-ID.
+// Clients must reload the state they track with events
+const EventReset = "reset"
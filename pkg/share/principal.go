@@ -0,0 +1,10 @@
+package share
+
+// ContextPrincipal is the request context key for the Principal,
+// set by the auth middleware
+const ContextPrincipal = "principal"
+
+// Principal is the authenticated user making the request
+type Principal struct {
+	ID string `json:"id"`
+}
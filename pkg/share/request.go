@@ -0,0 +1,19 @@
+package share
+
+// HelloRequest is bound from the route params and query string
+type HelloRequest struct {
+	Name     string `json:"name" param:"name" validate:"required,max=64"`
+	Greeting string `json:"greeting" query:"greeting" validate:"enum=hello|hi|howzit"`
+}
+
+// StreamRequest is bound from the query string
+type StreamRequest struct {
+	Format string `json:"format" query:"format" validate:"enum=ndjson|array"`
+}
+
+// PageRequest lists the query params parsed by the paginator,
+// the limit defaults to the paginator DefaultLimit
+type PageRequest struct {
+	Limit  int    `json:"limit" query:"limit" validate:"min=1"`
+	Cursor string `json:"cursor" query:"cursor"`
+}
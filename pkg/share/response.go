@@ -4,6 +4,26 @@ type Response struct {
 	Message string `json:"message"`
 }
 
+// Messenger is implemented by responses with a message,
+// the message is logged when the response is written
+type Messenger interface {
+	ResponseMessage() string
+}
+
+func (r Response) ResponseMessage() string {
+	return r.Message
+}
+
+// WelcomeResponse is the welcome message for version 2 of the API
+type WelcomeResponse struct {
+	Message string `json:"message"`
+	Version int    `json:"version"`
+}
+
+func (r WelcomeResponse) ResponseMessage() string {
+	return r.Message
+}
+
 type ErrResponse struct {
 	Message   string `json:"message"`
 	RequestID string `json:"request_id"`
@@ -15,3 +35,39 @@ type ClientVersion struct {
 	Version  string `json:"version"`
 	Checksum string `json:"checksum"`
 }
+
+// FieldError describes a validation failure for a single field,
+// Field is the JSON path, e.g. "items[0].name"
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrResponse lists every field that failed validation
+type ValidationErrResponse struct {
+	Message   string       `json:"message"`
+	RequestID string       `json:"request_id"`
+	Errors    []FieldError `json:"errors"`
+}
+
+// PageResponse is the standard envelope for paginated lists,
+// NextCursor is empty on the last page
+type PageResponse struct {
+	Items      interface{} `json:"items"`
+	Limit      int         `json:"limit"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// UploadedFile describes a file received by an upload route,
+// ContentType is detected from the content
+type UploadedFile struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+type UploadResponse struct {
+	Files []UploadedFile `json:"files"`
+}
@@ -0,0 +1,24 @@
+package share
+
+// Route policies, see Route
+const (
+	// PolicyPublic routes do not require a token
+	PolicyPublic = "public"
+	// PolicyToken routes require a valid token, this is the default
+	PolicyToken = "token"
+)
+
+// Route describes a registered route,
+// Pattern uses the router syntax, e.g. "/hello/:name"
+type Route struct {
+	Method      string   `json:"method"`
+	Pattern     string   `json:"pattern"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Policy      string   `json:"policy"`
+}
+
+type RoutesResponse struct {
+	Routes []Route `json:"routes"`
+}
@@ -0,0 +1,9 @@
+package share
+
+// HeaderAPIVersion selects the API version, e.g. "API-Version: 2".
+// The version is also set on the response
+const HeaderAPIVersion = "API-Version"
+
+// ContextAPIVersion is the request context key for the API version,
+// set by the version middleware
+const ContextAPIVersion = "api_version"
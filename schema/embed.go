@@ -0,0 +1,10 @@
+// Package schema embeds the JSON Schemas into the server binary,
+// use pkg/schema to load them
+package schema
+
+import "embed"
+
+// FS contains the JSON Schemas in this dir
+//
+//go:embed *.json
+var FS embed.FS
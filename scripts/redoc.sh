@@ -0,0 +1,25 @@
+#!/usr/bin/env bash
+set -eu                   # exit on error or undefined variable
+bash -c 'set -o pipefail' # return code of first cmd to fail in a pipeline
+
+APP_DIR=${APP_DIR}
+
+# Pinned version of the Redoc bundle served by the docs page,
+# set REDOC_VERSION to upgrade.
+# The bundle must be committed, TestRedoc in www/embed_test.go fails without it
+REDOC_VERSION=${REDOC_VERSION:-$(cat "${APP_DIR}"/www/js/redoc.version)}
+
+TMP_DIR=$(mktemp -d)
+trap 'rm -rf "${TMP_DIR}"' EXIT
+
+# npm verifies the package integrity against the registry
+cd "${TMP_DIR}"
+TARBALL=$(npm pack --silent "redoc@${REDOC_VERSION}")
+tar -xzf "${TARBALL}" package/bundles/redoc.standalone.js package/LICENSE
+
+# The bundle is embedded, see www/embed.go
+cp package/bundles/redoc.standalone.js "${APP_DIR}"/www/js/redoc.standalone.js
+cp package/LICENSE "${APP_DIR}"/www/js/redoc.LICENSE
+echo "${REDOC_VERSION}" > "${APP_DIR}"/www/js/redoc.version
+
+echo "done $(basename "$0")"
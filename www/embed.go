@@ -0,0 +1,10 @@
+// Package www embeds static assets and templates into the server binary
+package www
+
+import "embed"
+
+// FS contains the files in this dir, excluding Go source.
+// New dirs must be added to the list, TestFS compares it with the dir
+//
+//go:embed favicon.ico css data js layouts partials pages
+var FS embed.FS
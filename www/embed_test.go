@@ -0,0 +1,63 @@
+package www_test
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mozey/httprouter-util/www"
+	"github.com/stretchr/testify/require"
+)
+
+// TestFS fails if a file in this dir is missing from the go:embed list
+func TestFS(t *testing.T) {
+	onDisk := []string{}
+	err := fs.WalkDir(os.DirFS("."), ".", func(
+		path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if path != "." &&
+			(strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+			// Not embedded by directory patterns
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && filepath.Ext(name) != ".go" {
+			onDisk = append(onDisk, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	embedded := []string{}
+	err = fs.WalkDir(www.FS, ".", func(
+		path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			embedded = append(embedded, path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, onDisk, embedded)
+}
+
+// TestRedoc fails if the Redoc bundle loaded by the docs page is missing,
+// add it with scripts/redoc.sh
+func TestRedoc(t *testing.T) {
+	for _, name := range []string{
+		"js/redoc.standalone.js", "js/redoc.LICENSE", "js/redoc.version"} {
+		_, err := fs.Stat(www.FS, name)
+		require.NoError(t, err,
+			"missing %s, run APP_DIR=$(pwd) ./scripts/redoc.sh", name)
+	}
+}